@@ -0,0 +1,877 @@
+package compiler
+
+import (
+	"fmt"
+	"junk/ast"
+	"junk/code"
+	"junk/evaluator"
+	"junk/object"
+	"strings"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	err error // the first operand that did not fit its instruction
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loopContext // enclosing loops, innermost last
+}
+
+// loopContext tracks the jumps of break and continue statements in a loop,
+// which are patched once the loop has been compiled.
+type loopContext struct {
+	breakJumps    []int
+	continueJumps []int
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	NumLocals    int // local slots of the main program, used by block scopes
+}
+
+// infixOpcodes maps infix operators to the opcodes that implement them.
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTableWithBuiltins(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState creates a compiler that continues from an earlier one, so
+// that globals and constants survive between REPL inputs.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// NewSymbolTableWithBuiltins returns a global symbol table that knows about
+// all builtins, ready to be passed to NewWithState.
+func NewSymbolTableWithBuiltins() *SymbolTable {
+	symbolTable := NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+	return symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if err := c.compile(node); err != nil {
+		return err
+	}
+	return c.err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
+
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("break outside loop")
+		}
+		loop.breakJumps = append(loop.breakJumps, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("continue outside loop")
+		}
+		loop.continueJumps = append(loop.continueJumps, c.emit(code.OpJump, 9999))
+
+	// Expressions
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInt{Value: node.Big}
+		}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return fmt.Errorf("quote is only supported by the evaluator")
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return fmt.Errorf("%T is not supported by the compiler", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit an `OpJumpNotTruthy` with a bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	// Emit an `OpJump` with a bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+// compileLogicalExpression compiles && and || into jumps, so that the right
+// operand only runs when the left one does not decide the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	var toTrue, toFalse []int
+	if node.Operator == "&&" {
+		toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	} else {
+		// a falsy left operand falls through to the right one
+		toRightPos := c.emit(code.OpJumpNotTruthy, 9999)
+		toTrue = append(toTrue, c.emit(code.OpJump, 9999))
+		c.changeOperand(toRightPos, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+
+	for _, pos := range toTrue {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	for _, pos := range toFalse {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpFalse)
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockValue compiles a block whose value is used, leaving exactly
+// one value on the stack: the value of its last expression or null.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if len(c.currentInstructions()) > start && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	conditionPos := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	loop := c.enterLoop()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.leaveLoop()
+
+	c.emit(code.OpJump, conditionPos)
+
+	afterBodyPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterBodyPos)
+	c.patchLoopJumps(loop, conditionPos, afterBodyPos)
+
+	return nil
+}
+
+// compileForStatement compiles a C-style loop. The init variables and the
+// body get block scopes, and their locals are detached from closures at
+// the end of every iteration, so that each iteration has fresh bindings.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	loopTable := c.enterBlock()
+
+	if node.Init != nil {
+		if err := c.Compile(node.Init); err != nil {
+			return err
+		}
+	}
+
+	conditionPos := len(c.currentInstructions())
+	jumpNotTruthyPos := -1
+	if node.Condition != nil {
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	loop := c.enterLoop()
+	bodyTable := c.enterBlock()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.leaveBlock()
+	c.leaveLoop()
+
+	continuePos := len(c.currentInstructions())
+	c.detachLocals(bodyTable)
+	c.detachLocals(loopTable)
+
+	if node.Update != nil {
+		if err := c.Compile(node.Update); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, conditionPos)
+
+	breakPos := c.detachOnBreak(loop, bodyTable, loopTable)
+
+	afterBodyPos := len(c.currentInstructions())
+	if jumpNotTruthyPos >= 0 {
+		c.changeOperand(jumpNotTruthyPos, afterBodyPos)
+	}
+	c.patchLoopJumps(loop, continuePos, breakPos)
+
+	c.leaveBlock()
+	return nil
+}
+
+// compileForInStatement compiles a for-in loop. OpIter turns the iterable
+// into an iterator, kept in a hidden local, and OpIterNext pushes the
+// values of the next iteration or jumps out of the loop when it is done.
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+
+	numVars := 1
+	if node.Key != nil {
+		numVars = 2
+	}
+	c.emit(code.OpIter, numVars)
+
+	loopTable := c.enterBlock()
+	iterator := loopTable.DefineHidden()
+	c.emit(code.OpSetLocal, iterator.Index)
+
+	var key Symbol
+	if node.Key != nil {
+		key = loopTable.Define(node.Key.Value)
+	}
+	value := loopTable.Define(node.Value.Value)
+
+	loopStartPos := len(c.currentInstructions())
+	c.emit(code.OpGetLocal, iterator.Index)
+	iterNextPos := c.emit(code.OpIterNext, 9999)
+	c.emit(code.OpSetLocal, value.Index)
+	if node.Key != nil {
+		c.emit(code.OpSetLocal, key.Index)
+	}
+
+	loop := c.enterLoop()
+	bodyTable := c.enterBlock()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.leaveBlock()
+	c.leaveLoop()
+
+	continuePos := len(c.currentInstructions())
+	c.detachLocals(bodyTable)
+	c.detachLocals(loopTable)
+	c.emit(code.OpJump, loopStartPos)
+
+	breakPos := c.detachOnBreak(loop, bodyTable, loopTable)
+
+	afterBodyPos := len(c.currentInstructions())
+	c.changeOperand(iterNextPos, afterBodyPos)
+	c.patchLoopJumps(loop, continuePos, breakPos)
+
+	c.leaveBlock()
+	return nil
+}
+
+func (c *Compiler) enterBlock() *SymbolTable {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	return c.symbolTable
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+// detachOnBreak emits the detach of the locals of blocks for the break
+// statements of loop, which skip the detach at the end of the iteration.
+// It returns the position the breaks jump to.
+func (c *Compiler) detachOnBreak(loop *loopContext, blocks ...*SymbolTable) int {
+	breakPos := len(c.currentInstructions())
+	if len(loop.breakJumps) > 0 {
+		for _, block := range blocks {
+			c.detachLocals(block)
+		}
+	}
+	return breakPos
+}
+
+// detachLocals emits an OpDetachLocal for every local of a block, so that
+// closures created so far keep their own copy of it.
+func (c *Compiler) detachLocals(block *SymbolTable) {
+	for _, symbol := range block.blockLocals() {
+		c.emit(code.OpDetachLocal, symbol.Index)
+	}
+}
+
+func (c *Compiler) patchLoopJumps(loop *loopContext, continuePos, breakPos int) {
+	for _, pos := range loop.continueJumps {
+		c.changeOperand(pos, continuePos)
+	}
+	for _, pos := range loop.breakJumps {
+		c.changeOperand(pos, breakPos)
+	}
+}
+
+func (c *Compiler) enterLoop() *loopContext {
+	loop := &loopContext{}
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loop)
+	return loop
+}
+
+func (c *Compiler) leaveLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+// currentLoop returns the innermost loop of the current function, or nil.
+func (c *Compiler) currentLoop() *loopContext {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	params := make([]Symbol, len(node.Parameters))
+	for i, p := range node.Parameters {
+		params[i] = c.symbolTable.Define(p.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	numDefaults := 0
+	for i, def := range node.Defaults {
+		if def == nil {
+			continue
+		}
+		numDefaults++
+		if err := c.compileDefault(params, i, def); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   numDefaults,
+		Variadic:      node.Rest != nil,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+// compileDefault sets parameter i to the value of def unless the caller
+// passed an argument for it. Like in the evaluator, a default sees the
+// parameters before its own but not those after it.
+func (c *Compiler) compileDefault(params []Symbol, i int, def ast.Expression) error {
+	later := params[i+1:]
+	for _, p := range later {
+		delete(c.symbolTable.store, p.Name)
+	}
+
+	jumpPos := c.emit(code.OpJumpIfArgument, i, 9999)
+	err := c.Compile(def)
+	c.emit(code.OpSetLocal, params[i].Index)
+	c.replaceInstruction(jumpPos, c.makeInstruction(code.OpJumpIfArgument, i, len(c.currentInstructions())))
+
+	for _, p := range later {
+		c.symbolTable.store[p.Name] = p
+	}
+	return err
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		NumLocals:    c.symbolTable.NumMainLocals(),
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := c.makeInstruction(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// makeInstruction is code.Make, but records an error for an operand that
+// does not fit its width, such as the index of the 257th local of a
+// function, instead of letting Make cut it off.
+func (c *Compiler) makeInstruction(op code.Opcode, operands ...int) []byte {
+	def, err := code.Lookup(byte(op))
+	if err == nil && c.err == nil {
+		for i, operand := range operands {
+			max := 1<<(8*def.OperandWidths[i]) - 1
+			if operand < 0 || operand > max {
+				c.err = fmt.Errorf("%s operand %d out of range: %d, max %d",
+					def.Name, i, operand, max)
+				break
+			}
+		}
+	}
+
+	return code.Make(op, operands...)
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := c.makeInstruction(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// compileAssignExpression stores a new value in an existing variable and
+// leaves that value on the stack as the result of the expression.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	if target, ok := node.Target.(*ast.IndexExpression); ok {
+		return c.compileIndexAssignExpression(target, node)
+	}
+
+	name := node.Target.(*ast.Identifier).Value
+
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		return fmt.Errorf("cannot assign to undefined variable: %s", name)
+	}
+	if symbol.Scope == BuiltinScope {
+		return fmt.Errorf("cannot assign to builtin: %s", name)
+	}
+	if c.symbolTable.isFunctionName(symbol) {
+		return fmt.Errorf("assigning to %s inside its own body is not supported by the compiler", name)
+	}
+
+	if node.Operator != "=" {
+		c.loadSymbol(symbol)
+	}
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	if node.Operator != "=" {
+		op, ok := infixOpcodes[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+	}
+
+	c.storeSymbol(symbol)
+	c.loadSymbol(symbol)
+	return nil
+}
+
+// compileIndexAssignExpression compiles `left[index] = value`. The operand
+// of OpSetIndex is the opcode of a compound operator, or 0 for plain `=`.
+func (c *Compiler) compileIndexAssignExpression(target *ast.IndexExpression, node *ast.AssignExpression) error {
+	op := 0
+	if node.Operator != "=" {
+		infixOp, ok := infixOpcodes[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		op = int(infixOp)
+	}
+
+	if err := c.Compile(target.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(target.Index); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	c.emit(code.OpSetIndex, op)
+	return nil
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// captureSymbol pushes a free variable for a new closure. Locals and free
+// variables are shared through cells, so that assignments on either side
+// are seen by the other.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
@@ -0,0 +1,203 @@
+package compiler
+
+import "sort"
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	block          bool // a block scope, such as a loop body, inside its Outer table
+	numBlockLocals int  // locals of block scopes in the main program
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Copy returns a table with the same symbols as s, which can be defined
+// into without changing s. The outer tables are shared.
+func (s *SymbolTable) Copy() *SymbolTable {
+	c := *s
+	c.store = make(map[string]Symbol, len(s.store))
+	for name, symbol := range s.store {
+		c.store[name] = symbol
+	}
+	c.FreeSymbols = append([]Symbol{}, s.FreeSymbols...)
+	return &c
+}
+
+// NewBlockSymbolTable creates the table of a block scope. Its names are
+// locals of the enclosing function, or of the main program when the block
+// is not inside a function.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+// Define binds name in the current scope. Defining a name twice in the same
+// scope reuses its slot, which mirrors how `let` rebinds a name in the
+// evaluator's environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	if s.block {
+		symbol := Symbol{Name: name, Scope: LocalScope, Index: s.allocLocal()}
+		s.store[name] = symbol
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// DefineHidden allocates a local slot that no name resolves to, for values
+// the compiler keeps around itself, such as the iterator of a for-in loop.
+func (s *SymbolTable) DefineHidden() Symbol {
+	return Symbol{Scope: LocalScope, Index: s.allocLocal()}
+}
+
+// allocLocal reserves a local slot in the function that owns s.
+func (s *SymbolTable) allocLocal() int {
+	owner := s
+	for owner.block {
+		owner = owner.Outer
+	}
+
+	if owner.Outer == nil { // the main program
+		owner.numBlockLocals++
+		return owner.numBlockLocals - 1
+	}
+
+	owner.numDefinitions++
+	return owner.numDefinitions - 1
+}
+
+// blockLocals returns the locals defined in a block table, by slot.
+func (s *SymbolTable) blockLocals() []Symbol {
+	locals := make([]Symbol, 0, len(s.store))
+	for _, symbol := range s.store {
+		if symbol.Scope == LocalScope {
+			locals = append(locals, symbol)
+		}
+	}
+	sort.Slice(locals, func(i, j int) bool { return locals[i].Index < locals[j].Index })
+	return locals
+}
+
+// NumMainLocals returns how many local slots the main program needs for
+// the block scopes defined in it.
+func (s *SymbolTable) NumMainLocals() int {
+	return s.numBlockLocals
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// isFunctionName reports whether symbol, resolved in s, is the name of an
+// enclosing function, either directly or through free variables. The
+// closure of such a function is captured as it is, not boxed in a cell.
+func (s *SymbolTable) isFunctionName(symbol Symbol) bool {
+	for table := s; ; table = table.Outer {
+		for table.block {
+			table = table.Outer
+		}
+
+		switch symbol.Scope {
+		case FunctionScope:
+			return true
+		case FreeScope:
+			symbol = table.FreeSymbols[symbol.Index]
+		default:
+			return false
+		}
+	}
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if s.block || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+
+		free := s.defineFree(symbol)
+		return free, true
+	}
+	return symbol, ok
+}
+
+// Names returns the sorted names defined directly in this table, leaving
+// out builtins.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, 0, len(s.store))
+	for name, symbol := range s.store {
+		if symbol.Scope != BuiltinScope {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,113 +1,356 @@
 package evaluator
 
-import "junk/object"
-
-var builtins = map[string]*object.Builtin{
-	"len": {
-		Func: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"junk/object"
+	"os"
+	"sort"
+	"strings"
+)
+
+// IO holds the streams that builtins such as puts and input use. A nil
+// stream stands for the matching stream of the process.
+type IO struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+}
+
+// builtins are the builtins of environments created without their own.
+var builtins = NewBuiltins(IO{})
+
+// NewBuiltins returns a new set of the standard builtins, which read from
+// and write to streams. Every set has the same names as BuiltinNames.
+func NewBuiltins(streams IO) map[string]*object.Builtin {
+	stdout, stderr := streams.Stdout, streams.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	var stdin *bufio.Reader
+	if streams.Stdin == nil {
+		stdin = bufio.NewReader(os.Stdin)
+	} else {
+		stdin = bufio.NewReader(streams.Stdin) // reuses streams.Stdin when it already is a *bufio.Reader
+	}
+
+	return map[string]*object.Builtin{
+		"len": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *object.Array:
+					return &object.Integer{Value: int64(len(arg.Elements))}
+				case *object.String:
+					return &object.Integer{Value: int64(len(arg.Value))}
+
+				default:
+					return newError("argument to `len` not supported, got %s", args[0].Type())
+				}
+			},
 		},
-	},
-	"first": {
-		Func: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-
-			return NULL
+		"first": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+
+				return NULL
+			},
 		},
-	},
-	"last": {
-		Func: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
-
-			return NULL
+		"last": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					return arr.Elements[length-1]
+				}
+
+				return NULL
+			},
+		},
+		"rest": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]object.Object, length-1, length-1)
+					copy(newElements, arr.Elements[1:length])
+					return &object.Array{Elements: newElements}
+				}
+
+				return NULL
+			},
 		},
-	},
-	"rest": {
-		Func: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
+		"push": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+
+				newElements := make([]object.Object, length+1, length+1)
+				copy(newElements, arr.Elements)
+				newElements[length] = args[1]
+
 				return &object.Array{Elements: newElements}
-			}
+			},
+		},
+		"keys": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.HASH_OBJ {
+					return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*object.Hash).Pairs()
+				keys := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					keys[i] = pair.Key
+				}
+
+				return &object.Array{Elements: keys}
+			},
+		},
+		"values": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.HASH_OBJ {
+					return newError("argument to `values` must be HASH, got %s", args[0].Type())
+				}
 
-			return NULL
+				pairs := args[0].(*object.Hash).Pairs()
+				values := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					values[i] = pair.Value
+				}
+
+				return &object.Array{Elements: values}
+			},
 		},
-	},
-	"push": {
-		Func: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-
-			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-
-			return &object.Array{Elements: newElements}
+		"entries": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.HASH_OBJ {
+					return newError("argument to `entries` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*object.Hash).Pairs()
+				entries := make([]object.Object, len(pairs))
+				for i, pair := range pairs {
+					entries[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+				}
+
+				return &object.Array{Elements: entries}
+			},
 		},
-	},
-	"puts": {
-		Func: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				println(arg.Inspect())
-			}
-			return NULL
+		"fromEntries": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError("argument to `fromEntries` must be ARRAY, got %s", args[0].Type())
+				}
+
+				hash := &object.Hash{}
+				for i, entry := range args[0].(*object.Array).Elements {
+					pair, ok := entry.(*object.Array)
+					if !ok || len(pair.Elements) != 2 {
+						return newError("entry %d to `fromEntries` must be a [key, value] ARRAY, got %s",
+							i, entry.Inspect())
+					}
+
+					key, ok := pair.Elements[0].(object.Hashable)
+					if !ok {
+						return newError("unusable as hash key: %s", pair.Elements[0].Type())
+					}
+					hash.Set(key.HashKey(), object.HashPair{Key: pair.Elements[0], Value: pair.Elements[1]})
+				}
+
+				return hash
+			},
 		},
-	},
+		"has": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.HASH_OBJ {
+					return newError("argument to `has` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				_, ok = args[0].(*object.Hash).Get(key.HashKey())
+				return nativeBoolToBooleanObject(ok)
+			},
+		},
+		"delete": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.HASH_OBJ {
+					return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				// like push, return a new hash and leave the argument alone
+				hash := copyHash(args[0].(*object.Hash))
+				hash.Delete(key.HashKey())
+				return hash
+			},
+		},
+		"merge": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != object.HASH_OBJ {
+					return newError("argument to `merge` must be HASH, got %s", args[0].Type())
+				}
+				if args[1].Type() != object.HASH_OBJ {
+					return newError("argument to `merge` must be HASH, got %s", args[1].Type())
+				}
+
+				hash := copyHash(args[0].(*object.Hash))
+				for _, pair := range args[1].(*object.Hash).Pairs() {
+					hash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+				}
+				return hash
+			},
+		},
+		"puts": {
+			Func: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(stdout, arg.Inspect())
+				}
+				return NULL
+			},
+		},
+		"eputs": {
+			Func: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(stderr, arg.Inspect())
+				}
+				return NULL
+			},
+		},
+		"input": {
+			Func: func(args ...object.Object) object.Object {
+				if len(args) > 1 {
+					return newError("wrong number of arguments. got=%d, want=0 or 1",
+						len(args))
+				}
+				if len(args) == 1 {
+					prompt, ok := args[0].(*object.String)
+					if !ok {
+						return newError("argument to `input` must be STRING, got %s", args[0].Type())
+					}
+					fmt.Fprint(stdout, prompt.Value)
+				}
+
+				line, err := stdin.ReadString('\n')
+				if err != nil && line == "" {
+					if err == io.EOF {
+						return NULL
+					}
+					return newError("cannot read input: %s", err)
+				}
+
+				line = strings.TrimSuffix(line, "\n")
+				line = strings.TrimSuffix(line, "\r")
+				return &object.String{Value: line}
+			},
+		},
+	}
+}
+
+// copyHash returns a new hash with the pairs of hash, in the same order.
+func copyHash(hash *object.Hash) *object.Hash {
+	hashCopy := &object.Hash{}
+	for _, pair := range hash.Pairs() {
+		hashCopy.Set(pair.Key.(object.Hashable).HashKey(), pair)
+	}
+	return hashCopy
+}
+
+// BuiltinNames returns the names of all builtins in a stable order, so that
+// the compiler and the virtual machine can refer to builtins by index.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
 }
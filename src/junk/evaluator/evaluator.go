@@ -4,15 +4,74 @@ import (
 	"fmt"
 	"junk/ast"
 	"junk/object"
+	"junk/token"
+	"math"
+	"math/big"
+	"strings"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Eval evaluates node in env. An error raised by node itself is given the
+// position of node and the function call it happened in. When env has a
+// budget, see EvalContext, every node is charged to it.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return evalNode(node, env, notTail)
+}
+
+// tailPosition says which parts of a node are in tail position of a
+// function body. A call in tail position is not made but returned as a
+// *tailCall; see applyFunction.
+type tailPosition int
+
+const (
+	notTail     tailPosition = iota // a node outside a function body, or in a loop
+	returnsTail                     // its return statements, as in a statement before the last one
+	valueTail                       // its return statements and the call that makes up its value
+)
+
+// evalNode evaluates node like Eval, with the parts given by tail in tail
+// position.
+func evalNode(node ast.Node, env *object.Environment, tail tailPosition) object.Object {
+	budget := env.Budget()
+	if budget != nil {
+		if errObj := spendStep(budget); errObj != nil {
+			return located(errObj, node, env)
+		}
+	}
+
+	result := eval(node, env, tail)
+
+	if budget != nil && allocates(node) {
+		if errObj := spendAllocations(budget, result); errObj != nil {
+			return located(errObj, node, env)
+		}
+	}
+
+	if errObj, ok := result.(*object.Error); ok && !errObj.Pos.IsValid() {
+		return located(errObj, node, env)
+	}
+
+	return result
+}
+
+// located gives errObj the position of node and the function call that
+// env belongs to.
+func located(errObj *object.Error, node ast.Node, env *object.Environment) *object.Error {
+	if node != nil {
+		errObj.Pos = node.Pos()
+		errObj.Frame = env.Frame()
+	}
+	return errObj
+}
+
+func eval(node ast.Node, env *object.Environment, tail tailPosition) object.Object {
 	switch node := node.(type) {
 
 	// Statements
@@ -20,18 +79,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return evalNode(node.Expression, env, tail)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, tail)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if tail != notTail {
+			tail = valueTail
+		}
+		val := evalNode(node.ReturnValue, env, tail)
+		if isError(val) || isTailCall(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
@@ -42,11 +110,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.WhileStatement:
 		return evalWhileExpression(node, env)
 
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	// Expressions
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, tail)
 
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
@@ -60,8 +134,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIndexExpression(left, index)
 
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -83,23 +163,34 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, env.CheckedArithmetic())
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixInEnv(node.Operator, left, right, env)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       node.Body,
+		}
 
 	case *ast.CallExpression:
 		if node.Function.TokenLiteral() == "quote" {
@@ -115,12 +206,153 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		if fn, ok := function.(*object.Function); ok && tail == valueTail {
+			return &tailCall{fn: fn, args: args}
+		}
+
+		return applyFunction(function, args, env, node.Pos())
 	}
 
 	return nil
 }
 
+// evalForStatement runs a C-style loop. The variables of the init statement
+// live in a scope of their own, which is copied before every update so
+// that closures created in the body keep the values of their iteration.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+
+	if fs.Init != nil {
+		if init := Eval(fs.Init, loopEnv); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		// the environment of the body, and the copy of loopEnv below
+		if errObj := spendEnvAllocations(env, 2); errObj != nil {
+			return errObj
+		}
+
+		result := Eval(fs.Body, object.NewEnclosedEnvironment(loopEnv))
+		switch result.(type) {
+		case *object.Break:
+			return NULL
+		case *object.ReturnValue, *object.Error:
+			return result
+		}
+
+		loopEnv = loopEnv.Copy()
+		if fs.Update != nil {
+			if update := Eval(fs.Update, loopEnv); isError(update) {
+				return update
+			}
+		}
+	}
+}
+
+// evalForInStatement runs the body once for every element of the iterable,
+// each time in a new scope holding the loop variables.
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	numVars := 1
+	if fs.Key != nil {
+		numVars = 2
+	}
+
+	// charged before ForInBindings creates them, so that a long string
+	// cannot get around the limit
+	if errObj := spendEnvAllocations(env, bindingCount(iterable)); errObj != nil {
+		return errObj
+	}
+
+	steps, err := ForInBindings(iterable, numVars)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		// the environments of the variables and of the body
+		if errObj := spendEnvAllocations(env, 2); errObj != nil {
+			return errObj
+		}
+
+		varsEnv := object.NewEnclosedEnvironment(env)
+		if fs.Key != nil {
+			varsEnv.Set(fs.Key.Value, step[0])
+		}
+		varsEnv.Set(fs.Value.Value, step[len(step)-1])
+
+		result := Eval(fs.Body, object.NewEnclosedEnvironment(varsEnv))
+		switch result.(type) {
+		case *object.Break:
+			return NULL
+		case *object.ReturnValue, *object.Error:
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// ForInBindings returns the values a for-in loop binds to its numVars
+// variables, one slice per iteration. Arrays yield their elements, hashes
+// their keys and strings their characters; a second variable gets the
+// element as well, after the index or key.
+func ForInBindings(iterable object.Object, numVars int) ([][]object.Object, *object.Error) {
+	var keys, values []object.Object
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, el := range iterable.Elements {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, el)
+		}
+	case *object.String:
+		i := 0 // counts characters, range gives byte offsets
+		for _, ch := range iterable.Value {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			i++
+			values = append(values, &object.String{Value: string(ch)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+		if numVars == 1 {
+			values = keys
+		}
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+
+	steps := make([][]object.Object, len(values))
+	for i := range values {
+		if numVars == 2 {
+			steps[i] = []object.Object{keys[i], values[i]}
+		} else {
+			steps[i] = []object.Object{values[i]}
+		}
+	}
+
+	return steps, nil
+}
+
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
@@ -132,22 +364,36 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside loop", result.Inspect())
 		}
 	}
 
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+// evalBlockStatement evaluates the statements of block. The last statement
+// is in the tail position of block, the return statements of the others
+// only when block is in tail position of a function body.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, tail tailPosition) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+	for i, statement := range block.Statements {
+		statementTail := tail
+		if tail == valueTail && i < len(block.Statements)-1 {
+			statementTail = returnsTail
+		}
+		result = evalNode(statement, env, statementTail)
+
+		if isTailCall(result) {
+			return result
+		}
 
 		if result != nil {
 			rt := result.Type()
 
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -156,19 +402,26 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// evalWhileExpression runs the body while the condition holds. A return or
+// an error in the body stops the loop and is passed up to the caller.
 func evalWhileExpression(we *ast.WhileStatement, env *object.Environment) object.Object {
-	condition := Eval(we.Condition, env)
-
-	if isError(condition) {
-		return condition
-	}
+	for {
+		condition := Eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
 
-	for isTruthy(condition) {
-		Eval(we.Body, env)
-		condition = Eval(we.Condition, env)
+		result := Eval(we.Body, env)
+		switch result.(type) {
+		case *object.Break:
+			return NULL
+		case *object.ReturnValue, *object.Error:
+			return result
+		}
 	}
-
-	return NULL
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -179,12 +432,12 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func evalPrefixExpression(operator string, right object.Object, checked bool) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return evalMinusPrefixOperatorExpression(right, checked)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -203,23 +456,56 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+func evalMinusPrefixOperatorExpression(right object.Object, checked bool) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			if checked {
+				return newError("integer overflow: -(%d)", right.Value)
+			}
+			return newInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return newInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+// evalInfixInEnv applies operator to left and right with the settings and
+// the budget of the program running in env.
+func evalInfixInEnv(operator string, left, right object.Object, env *object.Environment) object.Object {
+	if errObj := reserveString(env, operator, left, right); errObj != nil {
+		return errObj
+	}
+	return evalInfixExpression(operator, left, right, env.CheckedArithmetic())
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+// evalInfixExpression applies operator to left and right. When checked is
+// set, integer overflow is an error instead of promoting to a big integer.
+func evalInfixExpression(operator string, left, right object.Object, checked bool) object.Object {
 	switch {
+	case operator == "&&":
+		return nativeBoolToBooleanObject(isTruthy(left) && isTruthy(right))
+	case operator == "||":
+		return nativeBoolToBooleanObject(isTruthy(left) || isTruthy(right))
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right, checked)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right, nil))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right, nil))
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return repeatString(left.(*object.String), right.(*object.Integer))
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return repeatString(right.(*object.String), left.(*object.Integer))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
@@ -229,23 +515,160 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+func evalIntegerInfixExpression(operator string, left, right object.Object, checked bool) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		if rightVal == 0 && operator == "/" {
+			return newError("division by zero")
+		}
+		if rightVal == 0 && operator == "%" {
+			return newError("modulo by zero")
+		}
+
+		result, overflow := integerArithmetic(operator, leftVal, rightVal)
+		if overflow && checked {
+			return newError("integer overflow: %d %s %d", leftVal, operator, rightVal)
+		}
+		if overflow {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalBigIntInfixExpression handles arithmetic and comparison when at least
+// one operand is a big integer, or when int64 arithmetic overflowed.
+func evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case "+":
+		return newInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return newInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return newInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return newInteger(new(big.Int).Quo(leftVal, rightVal)) // truncated, like int64 division
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("modulo by zero")
+		}
+		return newInteger(new(big.Int).Rem(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// newInteger returns value as an Integer when it fits in an int64 and as a
+// BigInt otherwise.
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+// integerArithmetic applies an arithmetic operator to two integers with a
+// non-zero divisor. It returns the wrapped result and whether it overflowed.
+func integerArithmetic(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := left + right
+		return result, (left >= 0) == (right >= 0) && (result >= 0) != (left >= 0)
+	case "-":
+		result := left - right
+		return result, (left >= 0) != (right >= 0) && (result >= 0) != (left >= 0)
+	case "*":
+		result := left * right
+		overflow := left != 0 && (result/left != right || (left == -1 && right == math.MinInt64))
+		return result, overflow
+	case "/":
+		return left / right, left == math.MinInt64 && right == -1
+	default: // "%"
+		return left % right, false
+	}
+}
+
+// evalFloatInfixExpression handles arithmetic and comparison when at least
+// one operand is a float; an integer operand is converted to a float first.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -255,16 +678,34 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment, tail tailPosition) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalNode(ie.Consequence, env, tail)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalNode(ie.Alternative, env, tail)
 	} else {
 		return NULL
 	}
@@ -275,13 +716,24 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := lookupBuiltin(node.Value, env); ok {
 		return builtin
 	}
 
 	return newError("identifier not found: " + node.Value)
 }
 
+// lookupBuiltin finds a builtin among the builtins of env.
+func lookupBuiltin(name string, env *object.Environment) (*object.Builtin, bool) {
+	table := env.Builtins()
+	if table == nil {
+		table = builtins
+	}
+
+	builtin, ok := table[name]
+	return builtin, ok
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -298,13 +750,236 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+// evalAssignExpression updates an existing variable in the scope that
+// defines it. Compound operators such as += apply the matching infix
+// operator to the current value first.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	if target, ok := node.Target.(*ast.IndexExpression); ok {
+		return evalIndexAssignExpression(target, node.Operator, node.Value, env)
 	}
+
+	name := node.Target.(*ast.Identifier).Value
+
+	current, ok := env.Get(name)
+	if !ok {
+		if _, ok := lookupBuiltin(name, env); ok {
+			return newError("cannot assign to builtin: %s", name)
+		}
+		return newError("cannot assign to undefined variable: %s", name)
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		val = evalInfixInEnv(strings.TrimSuffix(node.Operator, "="), current, val, env)
+		if isError(val) {
+			return val
+		}
+	}
+
+	env.Assign(name, val)
+	return val
+}
+
+// evalIndexAssignExpression evaluates `left[index] = value`. The array or
+// hash is updated in place.
+func evalIndexAssignExpression(target *ast.IndexExpression, operator string, valueNode ast.Expression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	index := Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	value := Eval(valueNode, env)
+	if isError(value) {
+		return value
+	}
+
+	if operator != "=" {
+		current := evalIndexExpression(left, index)
+		if errObj := reserveString(env, strings.TrimSuffix(operator, "="), current, value); errObj != nil {
+			return errObj
+		}
+	}
+
+	return evalIndexAssignment(left, index, operator, value, env.CheckedArithmetic())
+}
+
+func evalIndexAssignment(left, index object.Object, operator string, value object.Object, checked bool) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d (length %d)", idx.Value, len(left.Elements))
+		}
+
+		if operator != "=" {
+			value = evalInfixExpression(strings.TrimSuffix(operator, "="), left.Elements[idx.Value], value, checked)
+			if isError(value) {
+				return value
+			}
+		}
+
+		left.Elements[idx.Value] = value
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		if operator != "=" {
+			value = evalInfixExpression(strings.TrimSuffix(operator, "="), evalHashIndexExpression(left, index), value, checked)
+			if isError(value) {
+				return value
+			}
+		}
+
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+
+	return value
+}
+
+// evalLogicalExpression evaluates && and ||, only evaluating the right
+// operand when the left one does not decide the result.
+func evalLogicalExpression(operator string, left object.Object, rightNode ast.Expression, env *object.Environment) object.Object {
+	if operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(rightNode, env)
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func repeatString(str *object.String, count *object.Integer) object.Object {
+	if count.Value < 0 {
+		return newError("negative repeat count: %d", count.Value)
+	}
+	if count.Value > 0 && int64(len(str.Value)) > math.MaxInt32/count.Value {
+		return newError("repeated string too long: %d * %d bytes", count.Value, len(str.Value))
+	}
+	return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+}
+
+// comparison is a pair of arrays or hashes being compared by objectsEqual.
+type comparison struct {
+	left, right object.Object
+}
+
+// objectsEqual reports whether left and right have the same value. Numbers
+// compare by value across types, strings by content and arrays and hashes
+// element by element; other objects are only equal to themselves. seen
+// holds the arrays and hashes being compared further up, so that a value
+// that contains itself does not recurse forever: a pair met again is taken
+// to be equal, and any difference is found on the way back.
+func objectsEqual(left, right object.Object, seen map[comparison]bool) bool {
+	if left == right {
+		return true
+	}
+	if isNumber(left) && isNumber(right) {
+		return evalInfixExpression("==", left, right, false) == TRUE
+	}
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+
+	case *object.Array:
+		right := right.(*object.Array)
+		if len(left.Elements) != len(right.Elements) {
+			return false
+		}
+
+		seen, done := markCompared(seen, left, right)
+		if done {
+			return true
+		}
+		for i, elem := range left.Elements {
+			if !objectsEqual(elem, right.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+
+	case *object.Hash:
+		right := right.(*object.Hash)
+		if left.Len() != right.Len() {
+			return false
+		}
+
+		seen, done := markCompared(seen, left, right)
+		if done {
+			return true
+		}
+		for _, pair := range left.Pairs() {
+			other, ok := right.Get(pair.Key.(object.Hashable).HashKey())
+			if !ok || !objectsEqual(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return false
+	}
+}
+
+// markCompared records that left and right are being compared, creating
+// seen if needed. done reports whether they already were.
+func markCompared(seen map[comparison]bool, left, right object.Object) (map[comparison]bool, bool) {
+	if seen == nil {
+		seen = map[comparison]bool{}
+	}
+	pair := comparison{left, right}
+	if seen[pair] {
+		return seen, true
+	}
+	seen[pair] = true
+	return seen, false
 }
 
 func evalIndexExpression(array, index object.Object) object.Object {
@@ -326,7 +1001,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return NULL
 	}
@@ -347,10 +1022,10 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pairNode := range node.Pairs {
+		key := Eval(pairNode.Key, env)
 		if isError(key) {
 			return key
 		}
@@ -360,45 +1035,161 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pairNode.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashkey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashkey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+
+// applyFunction calls fn with args. callerEnv and callPos describe the call
+// site, for the stack traces of errors raised inside fn.
+func applyFunction(fn object.Object, args []object.Object, callerEnv *object.Environment, callPos token.Position) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		caller, limitsEnv := callerEnv.Frame(), callerEnv
+		if callerEnv == nil { // called back from a builtin, see Apply
+			limitsEnv = fn.Env
+			if budget := fn.Env.Budget(); budget != nil {
+				caller = budget.Caller
+			}
+		}
+		depth := 1
+		if caller != nil {
+			depth = caller.Depth + 1
+		}
+		if depth > maxCallDepth(limitsEnv) {
+			return &object.Error{Message: ErrCallDepthLimit.Error(), Cause: ErrCallDepthLimit}
+		}
+
+		// A call in tail position of the body comes back as a tailCall and
+		// replaces this one, so that the Go stack does not grow with it.
+		for {
+			frame := &object.Frame{Function: fn.Name, CallPos: callPos, Caller: caller, Depth: depth}
+			extendedEnv, errObj := extendFunctionEnv(fn, args, frame)
+			if errObj != nil {
+				return errObj
+			}
+			evaluated := evalNode(fn.Body, extendedEnv, valueTail)
+
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				return unwrapReturnValue(evaluated)
+			}
+			fn, args = call.fn, call.args
+		}
 	case *object.Builtin:
-		return fn.Func(args...)
+		budget := callerEnv.Budget()
+		if budget == nil {
+			return fn.Func(args...)
+		}
+
+		outer := budget.Caller
+		budget.Caller = callerEnv.Frame()
+		result := fn.Func(args...)
+		budget.Caller = outer
+
+		if errObj := spendAllocations(budget, result); errObj != nil {
+			return errObj
+		}
+		return result
 
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// tailCall is a call in tail position of a function body that is yet to be
+// made by applyFunction. It never escapes from the evaluator.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+func isTailCall(obj object.Object) bool {
+	_, ok := obj.(*tailCall)
+	return ok
+}
+
+// extendFunctionEnv binds the parameters of fn to args in a new call
+// environment. A missing argument takes the default value of its
+// parameter, evaluated in the new environment, and a rest parameter takes
+// an array of the remaining arguments.
+func extendFunctionEnv(fn *object.Function, args []object.Object, frame *object.Frame) (*object.Environment, object.Object) {
+	required := len(fn.Parameters)
+	for i := range fn.Parameters {
+		if i < len(fn.Defaults) && fn.Defaults[i] != nil {
+			required = i
+			break
+		}
+	}
+	if errObj := CheckArity(required, len(fn.Parameters), fn.Rest != nil, len(args)); errObj != nil {
+		return nil, errObj
+	}
+
+	env := object.NewCallEnvironment(fn.Env, frame)
+	if errObj := spendEnvAllocations(env, 1); errObj != nil {
+		return nil, errObj
+	}
 
 	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
+		if i < len(args) {
+			env.Set(param.Value, args[i])
+			continue
+		}
+
+		val := Eval(fn.Defaults[i], env)
+		if isError(val) {
+			return nil, val
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		restArray := &object.Array{Elements: rest}
+		if errObj := spendEnvAllocations(env, objectCount(restArray)); errObj != nil {
+			return nil, errObj
+		}
+		env.Set(fn.Rest.Value, restArray)
 	}
 
-	return env
+	return env, nil
+}
+
+// CheckArity returns an error when numArgs arguments do not fit a function
+// with required parameters followed by optional ones, up to total, and a
+// rest parameter when variadic is set.
+func CheckArity(required, total int, variadic bool, numArgs int) *object.Error {
+	switch {
+	case numArgs >= required && (variadic || numArgs <= total):
+		return nil
+	case variadic:
+		return newError("wrong number of arguments: want at least %d, got=%d", required, numArgs)
+	case required < total:
+		return newError("wrong number of arguments: want=%d to %d, got=%d", required, total, numArgs)
+	default:
+		return newError("wrong number of arguments: want=%d, got=%d", total, numArgs)
+	}
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
-	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue.Value
+	switch obj := obj.(type) {
+	case *object.ReturnValue:
+		return obj.Value
+	case *object.Break, *object.Continue: // loops do not reach across functions
+		return newError("%s outside loop", obj.Inspect())
 	}
 
 	return obj
@@ -427,3 +1218,42 @@ func isError(obj object.Object) bool {
 	}
 	return false
 }
+
+// EvalInfix applies an infix operator to two evaluated operands. The virtual
+// machine uses it so that both backends agree on operator semantics. When
+// checked is set, integer overflow is an error, see
+// object.Environment.SetCheckedArithmetic.
+func EvalInfix(operator string, left, right object.Object, checked bool) object.Object {
+	return evalInfixExpression(operator, left, right, checked)
+}
+
+// EvalIndexAssign stores value in left[index] for already evaluated
+// operands and returns the stored value. A compound operator such as "+="
+// is applied to the current element first, as by EvalInfix.
+func EvalIndexAssign(left, index object.Object, operator string, value object.Object, checked bool) object.Object {
+	return evalIndexAssignment(left, index, operator, value, checked)
+}
+
+// EvalPrefix applies a prefix operator to an evaluated operand. checked is
+// the same as for EvalInfix.
+func EvalPrefix(operator string, right object.Object, checked bool) object.Object {
+	return evalPrefixExpression(operator, right, checked)
+}
+
+// EvalIndex evaluates left[index] for already evaluated operands.
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Apply calls fn, a function or a builtin, with already evaluated args. It
+// lets Go code call back into junk functions. When a builtin of a program
+// with a budget calls back, the call is nested in the call of the builtin
+// and counts towards the call depth limit.
+func Apply(fn object.Object, args []object.Object) object.Object {
+	return applyFunction(fn, args, nil, token.Position{})
+}
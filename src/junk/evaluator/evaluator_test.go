@@ -1,6 +1,9 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"junk/lexer"
 	"junk/object"
 	"junk/parser"
@@ -27,6 +30,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37}, // parentheses
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 
 	for _, tt := range tests {
@@ -83,6 +89,19 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"\"", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 >= 3", false},
 	}
 
 	for _, tt := range tests {
@@ -91,6 +110,31 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let fail = func() { 1 + true }; false && fail()", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval("true && undefined")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: undefined" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -241,6 +285,26 @@ if (10 > 1) {
 			`{"name": "Monkey"}[func(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"let x = 0; 5 % x",
+			"modulo by zero",
+		},
+		{
+			"1 / 0.0",
+			"division by zero",
+		},
+		{
+			"1.5 / -0.0",
+			"division by zero",
+		},
+		{
+			"1.5 % 0",
+			"modulo by zero",
+		},
 	}
 
 	for _, tt := range tests {
@@ -260,6 +324,46 @@ if (10 > 1) {
 	}
 }
 
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"let min = -9223372036854775807 - 1; min / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", "integer overflow: -(-9223372036854775808)"},
+		{"let inc = func(x) { x + 1 }; inc(9223372036854775807)", "integer overflow: 9223372036854775807 + 1"},
+		{"let a = [9223372036854775807]; a[0] += 1", "integer overflow: 9223372036854775807 + 1"},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-4611686018427387904 * 2", -9223372036854775808},
+		{"let min = -9223372036854775807 - 1; min % -1", 0},
+	}
+
+	for _, checked := range []bool{false, true} {
+		for _, tt := range tests {
+			env := object.NewEnvironment()
+			env.SetCheckedArithmetic(checked)
+			evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+
+			message, isError := tt.expected.(string)
+			if !isError {
+				testIntegerObject(t, evaluated, int64(tt.expected.(int)))
+				continue
+			}
+
+			errObj, ok := evaluated.(*object.Error)
+			if checked && (!ok || errObj.Message != message) {
+				t.Errorf("%q: expected error %q, got=%s", tt.input, message, evaluated.Inspect())
+			}
+			if !checked && ok {
+				t.Errorf("%q: unexpected error %q", tt.input, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestLetStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -276,6 +380,108 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; a += 4; a", 5},
+		{"let a = 10; a -= 4", 6},
+		{"let a = 3; a *= 4; a", 12},
+		{"let a = 12; a /= 4; a", 3},
+		{"let a = 12; a %= 5; a", 2},
+		{"let a = 1; let b = a = 7; a + b", 14},
+		{"let counter = 0; let inc = func() { counter += 1 }; inc(); inc(); counter", 2},
+		{"let a = 1; let f = func() { let a = 10; a = 20; a }; f() + a", 21},
+		{`
+		let newCounter = func() {
+			let n = 0;
+			func() { n += 1; n };
+		};
+		let c = newCounter();
+		c(); c(); c();
+		`, 3},
+		{`
+		let sum = func(n) {
+			let i = 0;
+			let total = 0;
+			let add = func() { total += i };
+			while (i < n) { add(); i += 1 };
+			total;
+		};
+		sum(4);
+		`, 6},
+		{`
+		let outer = func() {
+			let n = 1;
+			let middle = func() { func() { n *= 10 } };
+			middle()();
+			n;
+		};
+		outer();
+		`, 10},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestIndexAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = [1, 2, 3]; a[0] = 10; a[0]", 10},
+		{"let a = [1, 2, 3]; a[2] += 5; a[2]", 8},
+		{"let a = [1, 2, 3]; let b = a; b[1] = 20; a[1]", 20},
+		{"let a = [[1], [2]]; a[1][0] *= 7; a[1][0]", 14},
+		{"let a = [0, 0]; let i = 0; while (i < 2) { a[i] = i + 1; i += 1 }; a[0] + a[1]", 3},
+		{"let h = {}; h[\"k\"] = 3; h[\"k\"]", 3},
+		{"let h = {\"k\": 3}; h[\"k\"] -= 1; h[\"k\"]", 2},
+		{"let h = {1: 1}; h[true] = 5; h[1] + h[true]", 6},
+		{"let h = {}; let f = func() { h[\"n\"] = 9 }; f(); h[\"n\"]", 9},
+		{"let a = [1]; a[0] = 4", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"x = 1", "cannot assign to undefined variable: x"},
+		{"let f = func() { y = 1 }; f()", "cannot assign to undefined variable: y"},
+		{"len = 1", "cannot assign to builtin: len"},
+		{"let a = 1; a += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1, 2]; a[2] = 3", "index out of range: 2 (length 2)"},
+		{"let a = [1, 2]; a[-1] = 3", "index out of range: -1 (length 2)"},
+		{"let a = [1, 2]; a[\"x\"] = 3", "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[[1]] = 3", "unusable as hash key: ARRAY"},
+		{"let h = {}; h[func(x) { x }] = 3", "unusable as hash key: FUNCTION"},
+		{"let s = \"abc\"; s[0] = \"x\"", "index assignment not supported: STRING"},
+		{"let h = {}; h[\"k\"] += 1", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "func(x) { x + 2; };"
 
@@ -318,6 +524,64 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = func(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = func(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = func(a, b = a * 2) { b }; f(4)", 8},
+		{"let f = func(a = 1, b = a + 1) { [a, b] }; f()", []int64{1, 2}},
+		{"let f = func(a, ...rest) { rest }; f(1, 2, 3)", []int64{2, 3}},
+		{"let f = func(a, ...rest) { rest }; f(1)", []int64{}},
+		{"let f = func(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7)", []int64{1, 5, 2}},
+		{"let f = func(a, b = 2) { func() { a + b } }; f(1)()", 3},
+		{"let f = func(a = b, b = 1) { a }; f()", "identifier not found: b"},
+		{"let f = func(x) { x }; f()", "wrong number of arguments: want=1, got=0"},
+		{"let f = func(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"let f = func(x, y = 1) { x }; f()", "wrong number of arguments: want=1 to 2, got=0"},
+		{"let f = func(x, y = 1) { x }; f(1, 2, 3)", "wrong number of arguments: want=1 to 2, got=3"},
+		{"let f = func(x, ...rest) { x }; f()", "wrong number of arguments: want at least 1, got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("%q: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("%q: wrong num of elements. want=%d, got=%d",
+					tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, e := range expected {
+				testIntegerObject(t, array.Elements[i], e)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	evaluated := testEval("func(a, b = 1, ...c) { a }")
+	if evaluated.Inspect() != "fn(a, b = 1, ...c) {\na\n}" {
+		t.Errorf("wrong Inspect(). got=%q", evaluated.Inspect())
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 	let newAdder = func(x) {
@@ -343,6 +607,116 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringRepetition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"ab" * 3`, "ababab"},
+		{`3 * "ab"`, "ababab"},
+		{`"ab" * 0`, ""},
+		{`"" * 5`, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%q: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%q: String has wrong value. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	errorTests := map[string]string{
+		`"ab" * -1`:                   "negative repeat count: -1",
+		`"ab" * 2.0`:                  "type mismatch: STRING * FLOAT",
+		`"ab" * 99999999999999999999`: "type mismatch: STRING * BIGINT",
+		`"ab" * 4000000000`:           "repeated string too long: 4000000000 * 2 bytes",
+		`"ab" - "b"`:                  "unknown operator: STRING - STRING",
+	}
+	for input, expected := range errorTests {
+		evaluated := testEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("%q: wrong result. want error %q, got=%s", input, expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" + "b" == "ab"`, true},
+		{`"1" == 1`, false},
+		{`"1" != 1`, true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, [2, \"x\"]] == [1, [2, \"x\"]]", true},
+		{"[1] == [1.0]", true},
+		{"[99999999999999999999] == [99999999999999999999]", true},
+		{"[] == []", true},
+		{"[true, false] == [true, false]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"{} == {}", true},
+		{"[{}] == {}", false},
+		{"let f = func() {}; f == f", true},
+		{"func() {} == func() {}", false},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b", true},
+		{"let a = [1]; a[0] = a; a == a", true},
+		{"let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; a == b", false},
+		{`let h = {"self": 1}; h["self"] = h; let g = {"self": 1}; g["self"] = g; h == g`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("input: %q", tt.input)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"a" > "b"`, false},
+		{`"abc" > "abb"`, true},
+		{`"ab" < "abc"`, true},
+		{`"" < "a"`, true},
+		{`"B" < "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("input: %q", tt.input)
+		}
+	}
+
+	errObj, ok := testEval(`"a" < 1`).(*object.Error)
+	if !ok || errObj.Message != "type mismatch: STRING < INTEGER" {
+		t.Errorf("wrong result for mixed comparison. got=%+v", errObj)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -388,6 +762,47 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys({"b": 1, "a": 2})`, "[b, a]"},
+		{`keys({})`, "[]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`entries({"b": 1, 2: [3]})`, "[[b, 1], [2, [3]]]"},
+		{`fromEntries([["b", 1], [2, true]])`, "{b: 1, 2: true}"},
+		{`fromEntries(entries({"x": 1, "y": 2}))`, "{x: 1, y: 2}"},
+		{`fromEntries([])`, "{}"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({1: 1}, 1)`, "true"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}"},
+		{`delete({"a": 1}, "z")`, "{a: 1}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`let h = {"a": 1}; merge(h, {"b": 2}); h`, "{a: 1}"},
+		{`keys([1])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+		{`values(1)`, "ERROR: argument to `values` must be HASH, got INTEGER"},
+		{`entries()`, "ERROR: wrong number of arguments. got=0, want=1"},
+		{`has({}, [1])`, "ERROR: unusable as hash key: ARRAY"},
+		{`has({})`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`delete([], 1)`, "ERROR: argument to `delete` must be HASH, got ARRAY"},
+		{`merge({}, [])`, "ERROR: argument to `merge` must be HASH, got ARRAY"},
+		{`merge({})`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`fromEntries({})`, "ERROR: argument to `fromEntries` must be ARRAY, got HASH"},
+		{`fromEntries([["a"]])`, "ERROR: entry 0 to `fromEntries` must be a [key, value] ARRAY, got [a]"},
+		{`fromEntries([[[1], 2]])`, "ERROR: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -489,12 +904,12 @@ func TestHashLiterals(t *testing.T) {
 		FALSE.HashKey():                            6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong number of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong number of pairs. got=%d", result.Len())
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -503,6 +918,55 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestInspectSelfReference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{"let a = [1, 2]; a[1] = [a, 3]; a", "[1, [[...], 3]]"},
+		{`let h = {"a": 1}; h["self"] = h; h`, "{a: 1, self: {...}}"},
+		{`let h = {}; let a = [h]; h["a"] = a; a`, "[{a: [...]}]"},
+		{"let b = [1]; [b, b]", "[[1], [1]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong Inspect(). want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	var out bytes.Buffer
+	env := object.NewEnvironmentWithBuiltins(NewBuiltins(IO{Stdout: &out}))
+	program := parser.New(lexer.New(`let a = [1]; a[0] = a; let h = {}; h[1] = h; puts(a, h)`)).ParseProgram()
+	Eval(program, env)
+	if out.String() != "[[...]]\n{1: {...}}\n" {
+		t.Errorf("wrong puts output. got=%q", out.String())
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"c": 1, "a": 2, "b": 3}`, "{c: 1, a: 2, b: 3}"},
+		{`{3: 1, 1: 2, true: 3, "x": 4}`, "{3: 1, 1: 2, true: 3, x: 4}"},
+		{`let h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, "{b: 4, a: 2, c: 3}"},
+		{`let s = ""; for (k in {"z": 1, "y": 2, "x": 3}) { s += k }; s`, "zyx"},
+		{`let order = ""; let f = func(s) { order += s; s }; {f("b"): f("1"), f("a"): f("2")}; order`, "b1a2"},
+		{`{"a": 1, "a": 2}`, "{a: 2}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -536,6 +1000,26 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1: 5}[1.0]`,
+			5,
+		},
+		{
+			`{-0.0: 5}[0]`,
+			5,
+		},
+		{
+			`{100000000000000000000: 5}[100000000000000000000 * 1.0]`,
+			5,
+		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{1.5: 5}[1]`,
+			nil,
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -569,3 +1053,407 @@ func TestWhileLoop(t *testing.T) {
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
+
+func TestWhileLoopReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = func() { while (true) { return 7; } }; f()", 7},
+		{"let f = func() { let i = 0; while (true) { i += 1; if (i == 3) { return i * 10; } } }; f()", 30},
+		{"let f = func() { while (true) { while (true) { return 1; } } return 2; }; f()", 1},
+		{"let f = func() { while (false) { return 1; } 2 }; f()", 2},
+		{"while (true) { return 5; } 6", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestWhileLoopErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"while (true) { 1 + true; }", "type mismatch: INTEGER + BOOLEAN"},
+		{"let i = 0; while (i < 3) { i += 1; if (i == 2) { foo } } i", "identifier not found: foo"},
+		{"let f = func() { break; }; while (true) { f(); }", "break outside loop"},
+		{"let i = 0; while (i < bar) { i += 1 }", "identifier not found: bar"},
+		{"let i = 0; while (i + true) { i += 1 }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sum = 0; for (let i = 0; i < 5; i += 1) { sum += i }; sum", 10},
+		{"let i = 0; for (; i < 3;) { i += 1 } i", 3},
+		{"let s = 0; for (let i = 0; ; i += 1) { if (i == 5) { break } if (i % 2 == 0) { continue } s += i }; s", 4},
+		{"let fs = []; for (let i = 0; i < 3; i += 1) { fs = push(fs, func() { i }) }; fs[0]() + fs[1]() * 10 + fs[2]() * 100", 210},
+		{"let f = func() { for (let i = 0; i < 10; i += 1) { if (i * i > 20) { return i } } -1 }; f()", 5},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x }; s", 6},
+		{"let s = 0; for (i, x in [10, 20]) { s += i * x }; s", 20},
+		{"let s = 0; for (k, v in {\"a\": 1, \"b\": 2}) { s += v }; s", 3},
+		{"let n = 0; for (k in {\"a\": 1, \"bc\": 2}) { n += len(k) }; n", 3},
+		{"let n = 0; for (c in \"héllo\") { n += 1 }; n", 5},
+		{"let s = 0; for (i, c in \"héllo\") { s = s * 10 + i }; s", 1234},
+		{"let s = 0; for (i, c in \"日本\") { if (c == \"本\") { s = i } }; s", 1},
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, func() { x }) }; fs[0]() + fs[1]()", 3},
+		{"let fs = []; for (x in [1, 2]) { let y = x * 10; fs = push(fs, func() { y }) }; fs[0]() + fs[1]()", 30},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue } if (x == 4) { break } s += x }; s", 4},
+		{"let f = func(arr) { for (x in arr) { if (x > 1) { return x } } 0 }; f([1, 5, 9])", 5},
+		{"let x = 7; for (x in [1, 2]) { }; x", 7},
+		{`
+		let count = func() {
+			let pairs = 0;
+			for (let i = 0; i < 3; i += 1) {
+				for (let j = 0; j < 3; j += 1) {
+					if (j == i) { continue }
+					pairs += 1;
+				}
+			}
+			pairs;
+		};
+		count();
+		`, 6},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestForLoopErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"for (let i = 0; i < 3; i += 1) { }; i", "identifier not found: i"},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"for (let i = 0; i < 3; i += true) { }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break; } } i", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue; } sum += i; } sum", 25},
+		{`
+		let i = 0;
+		let pairs = 0;
+		while (i < 3) {
+			i += 1;
+			let j = 0;
+			while (true) {
+				j += 1;
+				if (j > i) { break; }
+				pairs += 1;
+			}
+		}
+		pairs;
+		`, 6},
+		{`
+		let firstOver = func(arr, limit) {
+			let i = 0;
+			let found = -1;
+			while (i < len(arr)) {
+				if (arr[i] > limit) { found = arr[i]; break; }
+				i += 1;
+			}
+			found;
+		};
+		firstOver([1, 5, 9, 12], 6);
+		`, 9},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBreakAndContinueOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"break;", "break outside loop"},
+		{"if (true) { continue; }", "continue outside loop"},
+		{"let f = func() { break; }; f()", "break outside loop"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 4", "18446744073709551616"},
+		{"let min = -9223372036854775807 - 1; -min", "9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"123456789012345678901234567890 - 123456789012345678901234567889", "1"},
+		{"-99999999999999999999 / 7", "-14285714285714285714"},
+		{"-99999999999999999999 % 7", "-1"},
+		{"99999999999999999999 > 1", "true"},
+		{"1 >= 99999999999999999999", "false"},
+		{"99999999999999999999 == 99999999999999999999", "true"},
+		{"99999999999999999999 != 1", "true"},
+		{"99999999999999999999 * 0.5", "5e+19"},
+		{"{99999999999999999999: 1}[99999999999999999999]", "1"},
+		{"let fact = func(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)", "15511210043330985984000000"},
+		{"99999999999999999999 / 0", "ERROR: division by zero"},
+		{"99999999999999999999 % 0", "ERROR: modulo by zero"},
+		{"99999999999999999999 + true", "ERROR: type mismatch: BIGINT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// results that fit in an int64 again are plain integers
+	testIntegerObject(t, testEval("99999999999999999999 - 99999999999999999998"), 1)
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e-9", 1e-9},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2 * 1.25", 2.5},
+		{"5.5 - 6", -0.5},
+		{"(1 + 2.5) * 2", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func TestEvalMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"2.5 < 3", true},
+		{"3 > 2.5", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.5 == 1.5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + true;",
+			"  at <main> (1:1)\n",
+		},
+		{
+			`let inner = func(x) {
+  x + true
+};
+let outer = func(y) { inner(y) + 1 };
+outer(1);`,
+			"  at inner (2:3)\n  at outer (4:23)\n  at <main> (5:1)\n",
+		},
+		{
+			// a call in tail position replaces the frame of its caller
+			`let inner = func(x) { x + true };
+let outer = func(y) { inner(y) };
+outer(1);`,
+			"  at inner (1:23)\n  at <main> (3:1)\n",
+		},
+		{
+			"func() { -true }();",
+			"  at <anonymous> (1:10)\n  at <main> (1:1)\n",
+		},
+		{
+			"let f = func() { g() }; f();",
+			"  at f (1:18)\n  at <main> (1:25)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if trace := errObj.StackTrace(); trace != tt.expected {
+			t.Errorf("wrong stack trace for %q. expected=%q, got=%q",
+				tt.input, tt.expected, trace)
+		}
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected error
+	}{
+		{"while (true) {}", context.Background(), object.Limits{MaxSteps: 1000}, ErrStepLimit},
+		{"while (true) {}", canceled, object.Limits{}, context.Canceled},
+		{"let f = func(n) { 1 + f(n + 1) }; f(0)", context.Background(), object.Limits{MaxCallDepth: 50}, ErrCallDepthLimit},
+		{"let f = func(n) { 1 + f(n + 1) }; f(0)", context.Background(), object.Limits{}, ErrCallDepthLimit},
+		{"let a = []; while (true) { a = push(a, 1) }", context.Background(), object.Limits{MaxAllocations: 1000}, ErrAllocationLimit},
+		{"[1, 2, 3, 4, 5]", context.Background(), object.Limits{MaxAllocations: 5}, ErrAllocationLimit},
+		{`let s = "a" * 1000000; for (i, c in s) { }`, context.Background(), object.Limits{MaxAllocations: 1000}, ErrAllocationLimit},
+		{"let a = [1, 2, 3]; while (true) { for (x in a) { } }", context.Background(), object.Limits{MaxSteps: 100000, MaxAllocations: 1000}, ErrAllocationLimit},
+		{"for (let i = 0; true; ) { }", context.Background(), object.Limits{MaxSteps: 100000, MaxAllocations: 1000}, ErrAllocationLimit},
+		{`let s = ""; let t = "a"; while (true) { s += t }`, context.Background(), object.Limits{MaxSteps: 100000, MaxAllocations: 1000}, ErrAllocationLimit},
+		{"let f = func(...r) { 0 }; f(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)", context.Background(), object.Limits{MaxAllocations: 15}, ErrAllocationLimit},
+		{`"a" * 2000000000`, context.Background(), object.Limits{MaxAllocations: 1000000}, ErrAllocationLimit},
+		{`let s = "a" * 64000; s + s`, context.Background(), object.Limits{MaxAllocations: 1500}, ErrAllocationLimit},
+		{`let s = "ab"; s *= 2000000000`, context.Background(), object.Limits{MaxAllocations: 1000000}, ErrAllocationLimit},
+		{`let a = ["x"]; a[0] *= 2000000000`, context.Background(), object.Limits{MaxAllocations: 1000000}, ErrAllocationLimit},
+		{`"a" * 6400`, context.Background(), object.Limits{MaxAllocations: 110}, nil},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x }; s", context.Background(), object.Limits{MaxAllocations: 100}, nil},
+		{"let f = func(n) { if (n > 0) { f(n - 1) } }; f(49)", context.Background(), object.Limits{MaxCallDepth: 50}, nil},
+		{"[1, 2, 3, 4, 5]", context.Background(), object.Limits{MaxAllocations: 11}, nil},
+		{"1 + 2", context.Background(), object.Limits{MaxSteps: 10}, nil},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+
+		evaluated := EvalContext(tt.ctx, program, env, tt.limits)
+
+		errObj, isErr := evaluated.(*object.Error)
+		switch {
+		case tt.expected == nil && isErr:
+			t.Errorf("%q: unexpected error %q", tt.input, errObj.Message)
+		case tt.expected != nil && !isErr:
+			t.Errorf("%q: expected error %q, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		case tt.expected != nil && !errors.Is(errObj.Cause, tt.expected):
+			t.Errorf("%q: wrong cause. expected=%v, got=%v (%q)", tt.input, tt.expected, errObj.Cause, errObj.Message)
+		}
+
+		if env.Budget() != nil {
+			t.Errorf("%q: budget left behind in the environment", tt.input)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let count = func(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"let count = func(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(100000, 0)", 100000},
+		{"let f = func(n) { if (n > 0) { return f(n - 1) } 7 }; f(100000)", 7},
+		{"let f = func(n) { if (n > 0) { if (n % 2 == 0) { return f(n - 1) } return f(n - 1) } 7 }; f(100000)", 7},
+		{"let c = 0; let f = func(n) { if (n > 0) { f(n - 1) } c += 1; c }; f(3)", 4},
+		{`
+let even = func(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = func(n) { if (n == 0) { false } else { even(n - 1) } };
+if (even(100001)) { 1 } else { 0 }`, 0},
+		{"let f = func(n) { let g = func(m) { m * 2 }; g(n) + 1 }; f(20)", 41},
+		{"let f = func(n) { while (true) { return n; } }; f(7)", 7},
+		{"let f = func() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } }; f()", 20},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	evaluated := testEval("let f = func(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Cause != ErrCallDepthLimit {
+		t.Errorf("non-tail recursion is not limited. got=%s", evaluated.Inspect())
+	}
+}
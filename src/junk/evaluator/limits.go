@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"junk/ast"
+	"junk/object"
+	"junk/token"
+	"math"
+	"unicode/utf8"
+)
+
+// DefaultMaxCallDepth is the call depth limit of programs whose limits do
+// not set one.
+const DefaultMaxCallDepth = 10000
+
+// stringBytesPerObject is the number of bytes of a string that count as
+// one object against the allocation limit.
+const stringBytesPerObject = 64
+
+// contextCheckInterval is the number of steps between checks of the
+// context of a program.
+const contextCheckInterval = 1024
+
+// Causes of the errors of programs that hit a limit, see object.Error.
+// A canceled program has the error of its context as cause instead.
+var (
+	ErrStepLimit       = errors.New("step limit exceeded")
+	ErrCallDepthLimit  = errors.New("call depth limit exceeded")
+	ErrAllocationLimit = errors.New("allocation limit exceeded")
+)
+
+// EvalContext evaluates node in env like Eval, but stops with an error
+// when ctx is done or the program exceeds limits.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits object.Limits) object.Object {
+	old := env.SetBudget(object.NewBudget(ctx, limits))
+	defer env.SetBudget(old)
+
+	return Eval(node, env)
+}
+
+// ApplyContext calls fn like Apply, but stops with an error when ctx is
+// done or the call exceeds limits. env is the global environment of fn.
+func ApplyContext(ctx context.Context, fn object.Object, args []object.Object, env *object.Environment, limits object.Limits) object.Object {
+	old := env.SetBudget(object.NewBudget(ctx, limits))
+	defer env.SetBudget(old)
+
+	return applyFunction(fn, args, env, token.Position{})
+}
+
+// spendStep charges one evaluation step to budget.
+func spendStep(budget *object.Budget) *object.Error {
+	budget.Steps++
+
+	if max := budget.Limits.MaxSteps; max > 0 && budget.Steps > max {
+		return &object.Error{Message: ErrStepLimit.Error(), Cause: ErrStepLimit}
+	}
+
+	if budget.Steps%contextCheckInterval == 1 {
+		if err := budget.Context.Err(); err != nil {
+			return &object.Error{Message: "execution canceled: " + err.Error(), Cause: err}
+		}
+	}
+
+	return nil
+}
+
+// spendAllocations charges the objects in obj to budget.
+func spendAllocations(budget *object.Budget, obj object.Object) *object.Error {
+	return spendObjects(budget, objectCount(obj))
+}
+
+// spendEnvAllocations charges n objects to the budget of the program
+// running in env, if it has one. It is for the objects that evaluating a
+// node creates besides its result, such as the environments of calls and
+// loop iterations.
+func spendEnvAllocations(env *object.Environment, n int64) *object.Error {
+	budget := env.Budget()
+	if budget == nil {
+		return nil
+	}
+	return spendObjects(budget, n)
+}
+
+// spendObjects charges n objects to budget.
+func spendObjects(budget *object.Budget, n int64) *object.Error {
+	budget.Allocations += n
+
+	if max := budget.Limits.MaxAllocations; max > 0 && budget.Allocations > max {
+		return &object.Error{Message: ErrAllocationLimit.Error(), Cause: ErrAllocationLimit}
+	}
+
+	return nil
+}
+
+// reserveString refuses the string that operator would make of left and
+// right when it does not fit in the allocation limit of the program running
+// in env, before the string is built. evalNode charges it once it is.
+func reserveString(env *object.Environment, operator string, left, right object.Object) *object.Error {
+	budget := env.Budget()
+	if budget == nil || budget.Limits.MaxAllocations == 0 {
+		return nil
+	}
+
+	size, ok := stringSize(operator, left, right)
+	if ok && budget.Allocations+1+size/stringBytesPerObject > budget.Limits.MaxAllocations {
+		return &object.Error{Message: ErrAllocationLimit.Error(), Cause: ErrAllocationLimit}
+	}
+	return nil
+}
+
+// stringSize returns the length in bytes of the string that operator makes
+// of left and right by concatenation or repetition. It reports false for
+// operations that do not make a string.
+func stringSize(operator string, left, right object.Object) (int64, bool) {
+	switch operator {
+	case "+":
+		l, lok := left.(*object.String)
+		r, rok := right.(*object.String)
+		if lok && rok {
+			return int64(len(l.Value)) + int64(len(r.Value)), true
+		}
+	case "*":
+		str, ok := left.(*object.String)
+		count, cok := right.(*object.Integer)
+		if !ok {
+			str, ok = right.(*object.String)
+			count, cok = left.(*object.Integer)
+		}
+		if ok && cok && count.Value > 0 {
+			if int64(len(str.Value)) > math.MaxInt64/count.Value {
+				return math.MaxInt64, true
+			}
+			return int64(len(str.Value)) * count.Value, true
+		}
+	}
+	return 0, false
+}
+
+// allocates reports whether evaluating node creates its result. Other
+// nodes, such as identifiers, calls and plain assignments, hand out
+// existing objects; the results of builtins are charged by applyFunction.
+func allocates(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.ArrayLiteral,
+		*ast.HashLiteral, *ast.FunctionLiteral, *ast.InfixExpression, *ast.PrefixExpression:
+		return true
+	case *ast.AssignExpression:
+		return node.Operator != "="
+	default:
+		return false
+	}
+}
+
+// bindingCount is the number of objects ForInBindings creates for the
+// variables of a loop over iterable: an index for every element of an
+// array, and an index and a one-character string for every character of a
+// string.
+func bindingCount(iterable object.Object) int64 {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		return int64(len(iterable.Elements))
+	case *object.String:
+		return 2 * int64(utf8.RuneCountInString(iterable.Value))
+	default:
+		return 0
+	}
+}
+
+// objectCount is the number of objects obj counts as against the
+// allocation limit.
+func objectCount(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return 1 + int64(len(obj.Elements))
+	case *object.Hash:
+		return 1 + int64(obj.Len())
+	case *object.String:
+		return 1 + int64(len(obj.Value))/stringBytesPerObject
+	case nil, *object.Boolean, *object.Null, *object.Error:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// maxCallDepth returns the call depth limit of the program running in env.
+func maxCallDepth(env *object.Environment) int {
+	if budget := env.Budget(); budget != nil && budget.Limits.MaxCallDepth > 0 {
+		return budget.Limits.MaxCallDepth
+	}
+	return DefaultMaxCallDepth
+}
@@ -0,0 +1,443 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"junk/evaluator"
+	"junk/object"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject converts a Go value to a junk object:
+//
+//   - nil becomes null, and an object.Object is returned unchanged
+//   - bools, strings, floats, integers and *big.Int become the matching junk value
+//   - slices and arrays become arrays
+//   - maps with string, integer or bool keys become hashes
+//   - functions become builtins; see RegisterBuiltin
+func ToObject(value any) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return value, nil
+	case *big.Int:
+		return bigToObject(value), nil
+	}
+
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return bigToObject(new(big.Int).SetUint64(v.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := ToObject(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		pairs := make([]object.HashPair, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := ToObject(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := key.(object.Hashable); !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+
+			value, err := ToObject(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, object.HashPair{Key: key, Value: value})
+		}
+
+		// Go maps have no order, sort the keys so that the hash has one
+		sort.Slice(pairs, func(i, j int) bool { return keyLess(pairs[i].Key, pairs[j].Key) })
+
+		hash := &object.Hash{}
+		for _, pair := range pairs {
+			hash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+		}
+		return hash, nil
+
+	case reflect.Func:
+		return toBuiltin(v.Interface())
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return ToObject(v.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a junk value", v.Type())
+}
+
+func bigToObject(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// keyLess orders the keys of hashes converted from Go maps: by type, then
+// by value for numbers and strings, and by their text for the others.
+func keyLess(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+	if less, ok := evaluator.EvalInfix("<", a, b, false).(*object.Boolean); ok {
+		return less.Value
+	}
+	return a.Inspect() < b.Inspect()
+}
+
+// FromObject converts a junk object to a Go value. Integers become int64,
+// big integers *big.Int, floats float64, strings string, booleans bool,
+// null nil, arrays []any and hashes map[string]any, keyed by the Inspect
+// form of keys that are not strings. An array or hash that contains itself
+// becomes nil where it comes round again. Other objects are returned
+// unchanged.
+func FromObject(obj object.Object) any {
+	return fromValue(obj, map[object.Object]bool{})
+}
+
+// fromValue is FromObject, with seen holding the arrays and hashes being
+// converted further up.
+func fromValue(obj object.Object, seen map[object.Object]bool) any {
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		if seen[obj] {
+			return nil
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+	}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return nil
+	case *object.Array:
+		elements := make([]any, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = fromValue(element, seen)
+		}
+		return elements
+	case *object.Hash:
+		pairs := make(map[string]any, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key := pair.Key.Inspect()
+			if str, ok := pair.Key.(*object.String); ok {
+				key = str.Value
+			}
+			pairs[key] = fromValue(pair.Value, seen)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
+
+// fromObject converts obj to a Go value of type t.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	if integer, ok := obj.(*object.Integer); ok && t == bigIntType {
+		return reflect.ValueOf(big.NewInt(integer.Value)), nil
+	}
+
+	if obj == evaluator.NULL {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Func:
+		switch obj.(type) {
+		case *object.Function, *object.Builtin:
+			return toGoFunc(obj, t)
+		}
+
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			break
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, element := range arr.Elements {
+			value, err := fromObject(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(value)
+		}
+		return slice, nil
+
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			key, err := fromObject(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value, err := fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(key, value)
+		}
+		return m, nil
+	}
+
+	value := FromObject(obj)
+	if value == nil {
+		return reflect.Value{}, fmt.Errorf("cannot use NULL as %s", t)
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Kind() == reflect.Int64 && !reflect.Zero(t).OverflowInt(v.Int()) {
+			return v.Convert(t), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Kind() == reflect.Int64 && v.Int() >= 0 && !reflect.Zero(t).OverflowUint(uint64(v.Int())) {
+			return v.Convert(t), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.Kind() == reflect.Int64 || v.Kind() == reflect.Float64 {
+			return v.Convert(t), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Inspect(), t)
+}
+
+// toGoFunc wraps fn, a junk function or builtin, in a Go function of type
+// t. Arguments are converted with ToObject and the result with fromObject.
+// An error of fn is returned as a *RuntimeError when t has an error
+// result, and raised as a panic with a *RuntimeError otherwise; builtins
+// made by toBuiltin turn that panic back into a junk error.
+func toGoFunc(fn object.Object, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType,
+		t.NumOut() == 1 && t.Out(0) == errorType:
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", fn.Inspect(), t)
+	}
+	hasError := t.NumOut() == 2
+
+	call := func(in []reflect.Value) []reflect.Value {
+		if t.IsVariadic() {
+			last := in[len(in)-1]
+			in = in[:len(in)-1]
+			for i := 0; i < last.Len(); i++ {
+				in = append(in, last.Index(i))
+			}
+		}
+
+		out, err := callObject(fn, in, t)
+		if err != nil && !hasError {
+			panic(err)
+		}
+
+		results := []reflect.Value{}
+		if t.NumOut() > 0 {
+			if err != nil {
+				out = reflect.Zero(t.Out(0))
+			}
+			results = append(results, out)
+		}
+		if hasError {
+			errValue := reflect.Zero(errorType)
+			if err != nil {
+				errValue = reflect.ValueOf(&err).Elem()
+			}
+			results = append(results, errValue)
+		}
+		return results
+	}
+
+	return reflect.MakeFunc(t, call), nil
+}
+
+// callObject calls fn with the Go values in and converts its result to the
+// first result type of t, if it has one.
+func callObject(fn object.Object, in []reflect.Value, t reflect.Type) (reflect.Value, error) {
+	args := make([]object.Object, len(in))
+	for i, value := range in {
+		arg, err := ToObject(value.Interface())
+		if err != nil {
+			return reflect.Value{}, &RuntimeError{Err: &object.Error{
+				Message: fmt.Sprintf("argument %d: %s", i+1, err)}}
+		}
+		args[i] = arg
+	}
+
+	result := evaluator.Apply(fn, args)
+	if errObj, ok := result.(*object.Error); ok {
+		return reflect.Value{}, &RuntimeError{Err: errObj}
+	}
+	if t.NumOut() == 0 {
+		return reflect.Value{}, nil
+	}
+
+	out, err := fromObject(result, t.Out(0))
+	if err != nil {
+		return reflect.Value{}, &RuntimeError{Err: &object.Error{Message: "result: " + err.Error()}}
+	}
+	return out, nil
+}
+
+// toBuiltin wraps a Go function in a builtin. Arguments are converted with
+// fromObject to the parameter types of fn. A function may return nothing,
+// one value, or a value and an error; a non-nil error becomes a junk error.
+func toBuiltin(fn any) (*object.Builtin, error) {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return fn, nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Func: fn}, nil
+	case func(args ...object.Object) object.Object:
+		return &object.Builtin{Func: fn}, nil
+	}
+
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%T is not a function", fn)
+	}
+
+	t := v.Type()
+	switch {
+	case t.NumOut() > 2:
+		return nil, errors.New("functions may return at most two values")
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, errors.New("the second result of a function must be an error")
+	}
+
+	builtin := func(args ...object.Object) (result object.Object) {
+		in, errObj := builtinArguments(t, args)
+		if errObj != nil {
+			return errObj
+		}
+
+		// a junk function passed to fn as a Go function panics on errors
+		defer func() {
+			if r := recover(); r != nil {
+				runtimeErr, ok := r.(*RuntimeError)
+				if !ok {
+					panic(r)
+				}
+				result = runtimeErr.Err
+			}
+		}()
+
+		out := v.Call(in)
+		if len(out) > 0 && out[len(out)-1].Type() == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				var runtimeErr *RuntimeError
+				if errors.As(err, &runtimeErr) {
+					return runtimeErr.Err
+				}
+				return &object.Error{Message: err.Error()}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+
+		result, err := ToObject(out[0].Interface())
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return result
+	}
+
+	return &object.Builtin{Func: builtin}, nil
+}
+
+// builtinArguments converts the arguments of a call to a Go function of
+// type t.
+func builtinArguments(t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	numIn := t.NumIn()
+	if (t.IsVariadic() && len(args) < numIn-1) || (!t.IsVariadic() && len(args) != numIn) {
+		return nil, &object.Error{Message: fmt.Sprintf(
+			"wrong number of arguments. got=%d, want=%d", len(args), numIn)}
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if t.IsVariadic() && i >= numIn-1 {
+			paramType = t.In(numIn - 1).Elem()
+		} else {
+			paramType = t.In(i)
+		}
+
+		value, err := fromObject(arg, paramType)
+		if err != nil {
+			return nil, &object.Error{Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+		}
+		in[i] = value
+	}
+
+	return in, nil
+}
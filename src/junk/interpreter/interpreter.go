@@ -0,0 +1,188 @@
+// Package interpreter lets Go programs embed junk as a scripting language.
+//
+//	interp := interpreter.New(interpreter.Options{})
+//	interp.Define("limit", 10)
+//	interp.RegisterBuiltin("double", func(n int) int { return n * 2 })
+//	result, err := interp.Eval("double(limit)")
+//
+// Programs run on the tree-walking evaluator. Global bindings and macros are
+// kept between calls to Eval, like in the REPL.
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"junk/evaluator"
+	"junk/lexer"
+	"junk/object"
+	"junk/parser"
+	"strings"
+)
+
+// Options configures a new Interpreter.
+type Options struct {
+	Filename string // name used in error positions, "<eval>" when empty
+
+	// Streams of builtins such as puts and input. A nil stream stands for
+	// the matching stream of the process.
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Limits of every call to Eval and Call, see object.Limits.
+	Limits object.Limits
+
+	// CheckedArithmetic makes int64 overflow an error instead of promoting
+	// the result to a big integer.
+	CheckedArithmetic bool
+}
+
+// Interpreter runs junk programs that share one set of global bindings.
+type Interpreter struct {
+	filename string
+	limits   object.Limits
+
+	builtins map[string]*object.Builtin // standard builtins and those registered by the host
+	env      *object.Environment
+	macroEnv *object.Environment
+}
+
+// New returns an interpreter with the standard builtins and without any
+// global bindings.
+func New(opts Options) *Interpreter {
+	filename := opts.Filename
+	if filename == "" {
+		filename = "<eval>"
+	}
+
+	builtins := evaluator.NewBuiltins(evaluator.IO{
+		Stdout: opts.Stdout,
+		Stderr: opts.Stderr,
+		Stdin:  opts.Stdin,
+	})
+
+	i := &Interpreter{
+		filename: filename,
+		limits:   opts.Limits,
+		builtins: builtins,
+		env:      object.NewEnvironmentWithBuiltins(builtins),
+		macroEnv: object.NewEnvironmentWithBuiltins(builtins),
+	}
+	i.env.SetCheckedArithmetic(opts.CheckedArithmetic)
+	i.macroEnv.SetCheckedArithmetic(opts.CheckedArithmetic)
+	return i
+}
+
+// ParseError reports the syntax errors of a program passed to Eval.
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string { return strings.Join(e.Messages, "\n") }
+
+// RuntimeError reports an error raised while running junk code.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string { return e.Err.Message }
+
+// Unwrap returns the cause of the error: evaluator.ErrStepLimit and the
+// other limit errors, or the error of a canceled context.
+func (e *RuntimeError) Unwrap() error { return e.Err.Cause }
+
+// StackTrace returns the calls that were active when the error happened.
+func (e *RuntimeError) StackTrace() string { return e.Err.StackTrace() }
+
+// Eval runs src and returns the value of its last statement. Syntax errors
+// are returned as a *ParseError and runtime errors as a *RuntimeError.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval, but stops src with a *RuntimeError when ctx is
+// done.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	l := lexer.NewWithFilename(i.filename, src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, i.macroEnv)
+	expanded := evaluator.ExpandMacros(program, i.macroEnv)
+
+	return result(evaluator.EvalContext(ctx, expanded, i.env, i.limits))
+}
+
+// Define binds name to value in the global scope. value is converted with
+// ToObject.
+func (i *Interpreter) Define(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return fmt.Errorf("cannot define %s: %w", name, err)
+	}
+
+	i.env.Set(name, obj)
+	return nil
+}
+
+// RegisterBuiltin makes fn callable from junk as name, replacing a standard
+// builtin of the same name. fn is either an object.BuiltinFunction or any
+// Go function, whose arguments and results are converted as described in
+// ToObject. A parameter of function type accepts a junk function, which
+// fn can call like a Go function. Builtins only exist in this interpreter.
+func (i *Interpreter) RegisterBuiltin(name string, fn any) error {
+	builtin, err := toBuiltin(fn)
+	if err != nil {
+		return fmt.Errorf("cannot register %s: %w", name, err)
+	}
+
+	i.builtins[name] = builtin
+	return nil
+}
+
+// Get returns the value bound to name in the global scope.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Call calls the global function fnName with args converted by ToObject.
+func (i *Interpreter) Call(fnName string, args ...any) (object.Object, error) {
+	return i.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is like Call, but stops the function with a *RuntimeError
+// when ctx is done.
+func (i *Interpreter) CallContext(ctx context.Context, fnName string, args ...any) (object.Object, error) {
+	fn, ok := i.env.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("function not found: %s", fnName)
+	}
+
+	objects := make([]object.Object, len(args))
+	for n, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %w", n+1, fnName, err)
+		}
+		objects[n] = obj
+	}
+
+	return result(evaluator.ApplyContext(ctx, fn, objects, i.env, i.limits))
+}
+
+// result turns an error object returned by the evaluator into a Go error.
+// Programs without a value, such as a lone let statement, yield null.
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
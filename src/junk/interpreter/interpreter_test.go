@@ -0,0 +1,358 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"junk/evaluator"
+	"junk/object"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	interp := New(Options{})
+
+	if _, err := interp.Eval("let add = func(a, b) { a + b };"); err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+
+	result, err := interp.Eval("add(1, 2)")
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+	if result.Inspect() != "3" {
+		t.Errorf("wrong result. want=3, got=%s", result.Inspect())
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	interp := New(Options{Filename: "script.junk"})
+
+	_, err := interp.Eval("let = 1;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError, got=%T (%v)", err, err)
+	}
+
+	_, err = interp.Eval("let f = func() { 1 + true }; f();")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError, got=%T (%v)", err, err)
+	}
+	if err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+	if !strings.Contains(runtimeErr.StackTrace(), "at f (script.junk:1:18)") {
+		t.Errorf("wrong stack trace. got=%q", runtimeErr.StackTrace())
+	}
+}
+
+func TestDefine(t *testing.T) {
+	interp := New(Options{})
+
+	values := map[string]any{
+		"n":     42,
+		"big":   uint64(1 << 63),
+		"pi":    3.5,
+		"name":  "junk",
+		"ok":    true,
+		"none":  nil,
+		"list":  []any{1, "two", []int{3}},
+		"hash":  map[string]any{"a": 1},
+		"table": map[int]string{1: "one"},
+		"order": map[int]string{10: "ten", 9: "nine", 100: "hundred"},
+	}
+	for name, value := range values {
+		if err := interp.Define(name, value); err != nil {
+			t.Fatalf("Define(%q) error: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"n + 1", "43"},
+		{"big", "9223372036854775808"},
+		{"pi * 2", "7.0"},
+		{`name + "!"`, "junk!"},
+		{"ok", "true"},
+		{"none", "null"},
+		{"list", "[1, two, [3]]"},
+		{`hash["a"]`, "1"},
+		{"table[1]", "one"},
+		{"order", "{9: nine, 10: ten, 100: hundred}"},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Errorf("%q: Eval error: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if err := interp.Define("ch", make(chan int)); err == nil {
+		t.Errorf("expected an error for a channel")
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	interp := New(Options{})
+
+	builtins := map[string]any{
+		"double": func(n int) int { return n * 2 },
+		"join":   func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"sum": func(numbers []float64) float64 {
+			total := 0.0
+			for _, n := range numbers {
+				total += n
+			}
+			return total
+		},
+		"fail": func() (int, error) { return 0, errors.New("it failed") },
+		"keys": func(m map[string]any) int { return len(m) },
+		"raw":  func(args ...object.Object) object.Object { return args[0] },
+		"huge": func(n *big.Int) string { return n.String() },
+		"noop": func() {},
+		"each": func(xs []int, f func(int) int) []int {
+			result := make([]int, len(xs))
+			for i, x := range xs {
+				result[i] = f(x)
+			}
+			return result
+		},
+		"tryEach": func(xs []int, f func(int) (int, error)) ([]int, error) {
+			result := make([]int, len(xs))
+			for i, x := range xs {
+				y, err := f(x)
+				if err != nil {
+					return nil, err
+				}
+				result[i] = y
+			}
+			return result, nil
+		},
+		"spread": func(f func(...int) int, xs ...int) int { return f(xs...) },
+	}
+	for name, fn := range builtins {
+		if err := interp.RegisterBuiltin(name, fn); err != nil {
+			t.Fatalf("RegisterBuiltin(%q) error: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"double(21)", "42"},
+		{`join("-", "a", "b", "c")`, "a-b-c"},
+		{`join(",")`, ""},
+		{"sum([1, 2.5])", "3.5"},
+		{"fail()", "ERROR: it failed"},
+		{`keys({"a": 1, "b": 2})`, "2"},
+		{"raw(true)", "true"},
+		{"huge(99999999999999999999)", "99999999999999999999"},
+		{"huge(7)", "7"},
+		{"noop()", "null"},
+		{"double()", "ERROR: wrong number of arguments. got=0, want=1"},
+		{`double("x")`, "ERROR: argument 1: cannot use x as int"},
+		{"double(1.5)", "ERROR: argument 1: cannot use 1.5 as int"},
+		{"each([1, 2], func(x) { x * 2 })", "[2, 4]"},
+		{"each([1, 2], double)", "[2, 4]"},
+		{"each([1], func(x) { x + true })", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`each([1], func(x) { "s" })`, "ERROR: result: cannot use s as int"},
+		{"each([1], func(x, y) { x })", "ERROR: wrong number of arguments: want=2, got=1"},
+		{"tryEach([1, 2], func(x) { x + 1 })", "[2, 3]"},
+		{"tryEach([1], func(x) { x + true })", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"spread(func(...xs) { len(xs) }, 1, 2, 3)", "3"},
+		{"each([1], 1)", "ERROR: argument 2: cannot use 1 as func(int) int"},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Eval(tt.input)
+		var runtimeErr *RuntimeError
+		if errors.As(err, &runtimeErr) {
+			result = runtimeErr.Err
+		} else if err != nil {
+			t.Errorf("%q: Eval error: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%q: want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if err := interp.RegisterBuiltin("bad", 42); err == nil {
+		t.Errorf("expected an error for a non-function")
+	}
+	if err := interp.RegisterBuiltin("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected an error for a second result that is not an error")
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := New(Options{})
+
+	_, err := interp.Eval(`
+let greet = func(name, times) {
+  let result = "";
+  for (let i = 0; i < times; i += 1) { result += "hi " + name + "; " }
+  result
+};
+let apply = func(f, x) { f(x) };
+`)
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+
+	result, err := interp.Call("greet", "bob", 2)
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	if FromObject(result) != "hi bob; hi bob; " {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	result, err = interp.Call("apply", func(n int) int { return n + 1 }, 41)
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	if FromObject(result) != int64(42) {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	if _, err := interp.Call("missing"); err == nil || err.Error() != "function not found: missing" {
+		t.Errorf("wrong error for a missing function. got=%v", err)
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	interp := New(Options{})
+
+	result, err := interp.Eval(`[1, 2.5, "s", true, if (false) { 1 }, {"k": [1]}, {1: 2}]`)
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+
+	expected := []any{
+		int64(1), 2.5, "s", true, nil,
+		map[string]any{"k": []any{int64(1)}},
+		map[string]any{"1": int64(2)},
+	}
+	if got := FromObject(result); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong conversion. want=%#v, got=%#v", expected, got)
+	}
+
+	result, err = interp.Eval(`let a = [1]; a[0] = a; a`)
+	if err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+	if got := FromObject(result); !reflect.DeepEqual(got, []any{nil}) {
+		t.Errorf("wrong conversion of a self-referencing array. got=%#v", got)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	interp := New(Options{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("42\n")})
+
+	if _, err := interp.Eval(`puts("answer: " + input("? ")); eputs("done");`); err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+
+	if stdout.String() != "? answer: 42\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "done\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestBuiltinsArePerInterpreter(t *testing.T) {
+	first := New(Options{})
+	second := New(Options{})
+
+	if err := first.RegisterBuiltin("answer", func() int { return 42 }); err != nil {
+		t.Fatalf("RegisterBuiltin error: %s", err)
+	}
+
+	if _, err := first.Eval("answer = 1"); err == nil || err.Error() != "cannot assign to builtin: answer" {
+		t.Errorf("wrong error for assigning to a builtin. got=%v", err)
+	}
+
+	if _, err := second.Eval("answer()"); err == nil || err.Error() != "identifier not found: answer" {
+		t.Errorf("builtin leaked into another interpreter. got=%v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	interp := New(Options{Limits: object.Limits{MaxSteps: 10000}})
+
+	_, err := interp.Eval("while (true) {}")
+	if !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("expected step limit error, got=%v", err)
+	}
+
+	// the budget is renewed for every call
+	if _, err := interp.Eval("let loop = func(n) { while (n > 0) { n -= 1 } };"); err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+	if _, err := interp.Call("loop", 100); err != nil {
+		t.Errorf("Call error: %s", err)
+	}
+	if _, err := interp.Call("loop", 100000); !errors.Is(err, evaluator.ErrStepLimit) {
+		t.Errorf("expected step limit error, got=%v", err)
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	input := "let inc = func(x) { x + 1 }; inc(9223372036854775807)"
+
+	result, err := New(Options{}).Eval(input)
+	if err != nil || result.Inspect() != "9223372036854775808" {
+		t.Errorf("unchecked: wrong result. got=%v, err=%v", result, err)
+	}
+
+	_, err = New(Options{CheckedArithmetic: true}).Eval(input)
+	if err == nil || err.Error() != "integer overflow: 9223372036854775807 + 1" {
+		t.Errorf("checked: expected overflow error, got=%v", err)
+	}
+}
+
+func TestCallDepthThroughCallbacks(t *testing.T) {
+	interp := New(Options{Limits: object.Limits{MaxCallDepth: 50}})
+	interp.RegisterBuiltin("callit", func(f func(int) int, n int) int { return f(n) })
+
+	if _, err := interp.Eval("let g = func(n) { if (n == 0) { 0 } else { 1 + callit(g, n - 1) } };"); err != nil {
+		t.Fatalf("Eval error: %s", err)
+	}
+	if result, err := interp.Eval("g(20)"); err != nil || result.Inspect() != "20" {
+		t.Errorf("wrong result. got=%v, err=%v", result, err)
+	}
+	if _, err := interp.Eval("g(200)"); !errors.Is(err, evaluator.ErrCallDepthLimit) {
+		t.Errorf("expected call depth limit error, got=%v", err)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	interp := New(Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := interp.EvalContext(ctx, "while (true) {}")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got=%v", err)
+	}
+	if err != nil && err.Error() != "execution canceled: context deadline exceeded" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+}
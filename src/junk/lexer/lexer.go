@@ -1,27 +1,80 @@
 package lexer
 
 import (
+	"fmt"
 	"junk/token"
+	"strings"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // channel of chars being read
+	filename     string // name of the source file, used in token positions
+	position     int    // current position in input (points to current char)
+	readPosition int    // current reading position in input (after current char)
+	ch           byte   // channel of chars being read
+	line         int    // line of the current char
+	column       int    // column of the current char
+	keepComments bool   // emit comments as COMMENT tokens instead of skipping them
+	errors       []Error
+}
+
+// Error is a problem found by the lexer, such as an unterminated string or
+// an unknown escape sequence.
+type Error struct {
+	Pos     token.Position
+	Message string
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename creates a lexer whose token positions refer to filename.
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
+// SetKeepComments makes the lexer return comments as COMMENT tokens, so a
+// formatter can preserve them. By default comments are skipped like
+// whitespace. A leading "#!" line is a comment as well.
+func (l *Lexer) SetKeepComments(keep bool) {
+	l.keepComments = keep
+}
+
+// Errors returns every error found so far, in source order.
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+func (l *Lexer) error(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.eatWhitespace() // helper function
 
+	for l.atComment() {
+		start := l.currentPosition()
+		comment, terminated := l.readComment()
+
+		if !terminated {
+			l.error(start, "unterminated block comment")
+			return token.Token{Type: token.ILLEGAL, Literal: comment, Pos: start, End: l.currentPosition()}
+		}
+		if l.keepComments {
+			return token.Token{Type: token.COMMENT, Literal: comment, Pos: start, End: l.currentPosition()}
+		}
+
+		l.eatWhitespace()
+	}
+
+	start := l.currentPosition()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' { // peekChar is a helper function
@@ -42,8 +95,17 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			l.error(start, "illegal character %q", l.ch)
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newTwoCharToken('=', token.PLUS_ASSIGN, token.PLUS)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -53,7 +115,7 @@ func (l *Lexer) NextToken() token.Token {
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newTwoCharToken('=', token.MINUS_ASSIGN, token.MINUS)
 	case '!':
 		if l.peekChar() == '=' { // peekChar is a helper function
 			ch := l.ch
@@ -64,16 +126,49 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch) // newToken is a helper function
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.newTwoCharToken('=', token.SLASH_ASSIGN, token.SLASH)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.newTwoCharToken('=', token.ASTERISK_ASSIGN, token.ASTERISK)
+	case '%':
+		tok = l.newTwoCharToken('=', token.PERCENT_ASSIGN, token.PERCENT)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = l.newTwoCharToken('=', token.LT_EQ, token.LT)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = l.newTwoCharToken('=', token.GT_EQ, token.GT)
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken('&', token.AND, token.ILLEGAL)
+		} else {
+			l.error(start, "illegal character %q, did you mean &&?", l.ch)
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken('|', token.OR, token.ILLEGAL)
+		} else {
+			l.error(start, "illegal character %q, did you mean ||?", l.ch)
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '"':
+		literal, terminated := l.readString()
+		if !terminated {
+			l.error(start, "unterminated string")
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[start.Offset:l.position]}
+			tok.Pos, tok.End = start, l.currentPosition()
+			return tok
+		}
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = literal
+	case '`':
+		literal, terminated := l.readRawString()
+		if !terminated {
+			l.error(start, "unterminated raw string")
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[start.Offset:l.position]}
+			tok.Pos, tok.End = start, l.currentPosition()
+			return tok
+		}
+		tok.Type = token.STRING
+		tok.Literal = literal
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -81,17 +176,20 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) { // isLetter is a helper function
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal) // LookupIdent is a helper function
+			tok.Pos, tok.End = start, l.currentPosition()
 			return tok
 		} else if isDigit(l.ch) { // isDigit is a helper function
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos, tok.End = start, l.currentPosition()
 			return tok
 		} else {
+			l.error(start, "illegal character %q", l.ch)
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
 	l.readChar()
+	tok.Pos, tok.End = start, l.currentPosition()
 	return tok
 }
 
@@ -100,6 +198,11 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // ASCII code for "NUL" (null character)
 	} else {
@@ -108,14 +211,52 @@ func (l *Lexer) readChar() {
 
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column += 1
+}
+
+// currentPosition returns the source position of the current char.
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
-func (l *Lexer) readNumber() string { // helper function
+// readNumber reads an integer, or a float when the digits are followed by
+// a fraction ("3.14") and/or an exponent ("1e-9").
+func (l *Lexer) readNumber() (string, token.TokenType) { // helper function
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekCharAt(2))) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() { // helper function
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
 func (l *Lexer) readIdentifier() string { // helper function
@@ -140,22 +281,193 @@ func (l *Lexer) eatWhitespace() { // helper function
 	}
 }
 
+func (l *Lexer) atComment() bool { // helper function
+	return l.ch == '#' || l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// readComment reads a line comment up to (not including) the newline, or a
+// block comment including its delimiters. It reports false for a block
+// comment that is not closed before the end of the input.
+func (l *Lexer) readComment() (string, bool) {
+	position := l.position
+
+	if l.ch == '/' && l.peekChar() == '*' {
+		l.readChar()
+		l.readChar()
+		for !(l.ch == '*' && l.peekChar() == '/') {
+			if l.ch == 0 {
+				return l.input[position:l.position], false
+			}
+			l.readChar()
+		}
+		l.readChar()
+		l.readChar()
+		return l.input[position:l.position], true
+	}
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return l.input[position:l.position], true
+}
+
 func (l *Lexer) peekChar() byte { // helper function
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt returns the char n positions after the current one.
+func (l *Lexer) peekCharAt(n int) byte { // helper function
+	if l.position+n >= len(l.input) {
 		return 0
+	}
+	return l.input[l.position+n]
+}
+
+// readString reads a double quoted string and decodes its escape
+// sequences. It stops on the closing quote, and reports false when the
+// string is not closed before the end of the line.
+func (l *Lexer) readString() (string, bool) {
+	var out strings.Builder
+
+	l.readChar() // skip the opening quote
+	for l.ch != '"' {
+		switch l.ch {
+		case 0, '\n':
+			return out.String(), false
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteByte(l.ch)
+			l.readChar()
+		}
+	}
+
+	return out.String(), true
+}
+
+// readEscape decodes the escape sequence starting at the current backslash
+// and leaves the lexer on the char after it.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.currentPosition()
+	l.readChar() // skip the backslash
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '0':
+		out.WriteByte(0)
+	case '\\', '"', '\'':
+		out.WriteByte(l.ch)
+	case 'x':
+		l.readChar()
+		r, ok := l.readHex(2)
+		if !ok {
+			l.error(start, "invalid escape sequence: \\x must be followed by 2 hex digits")
+			return
+		}
+		out.WriteByte(byte(r))
+		return
+	case 'u':
+		l.readChar()
+		l.readUnicodeEscape(start, out)
+		return
+	case 0, '\n':
+		return // the string is unterminated, which readString reports
+	default:
+		l.error(start, "unknown escape sequence: \\%c", l.ch)
+		out.WriteByte('\\')
+		out.WriteByte(l.ch)
+	}
+
+	l.readChar()
+}
+
+// readUnicodeEscape decodes the part after "\u", either 4 hex digits or
+// 1 to 6 hex digits in braces, e.g. "\u00e9" or "\u{1F600}".
+func (l *Lexer) readUnicodeEscape(start token.Position, out *strings.Builder) {
+	var r rune
+	ok := true
+
+	if l.ch == '{' {
+		l.readChar()
+		digits := 0
+		for isHexDigit(l.ch) && digits < 6 {
+			r = r*16 + rune(hexValue(l.ch))
+			digits++
+			l.readChar()
+		}
+		if digits == 0 || l.ch != '}' {
+			ok = false
+		} else {
+			l.readChar()
+		}
 	} else {
-		return l.input[l.readPosition]
+		r, ok = l.readHex(4)
+	}
+
+	if !ok || !utf8.ValidRune(r) {
+		l.error(start, "invalid unicode escape sequence")
+		return
 	}
+
+	out.WriteRune(r)
 }
 
-func (l *Lexer) readString() string {
+// readHex reads exactly n hex digits.
+func (l *Lexer) readHex(n int) (rune, bool) {
+	var r rune
+	for i := 0; i < n; i++ {
+		if !isHexDigit(l.ch) {
+			return r, false
+		}
+		r = r*16 + rune(hexValue(l.ch))
+		l.readChar()
+	}
+	return r, true
+}
+
+// readRawString reads a backtick string. It takes the chars as they are,
+// may span several lines and reports false when it is never closed.
+func (l *Lexer) readRawString() (string, bool) {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.ch == '`' {
+			return l.input[position:l.position], true
+		}
+		if l.ch == 0 {
+			return l.input[position:l.position], false
 		}
 	}
+}
 
-	return l.input[position:l.position]
+// newTwoCharToken returns a token of type twoChar when the next char is
+// next, consuming it, and a single char token of type oneChar otherwise.
+func (l *Lexer) newTwoCharToken(next byte, twoChar, oneChar token.TokenType) token.Token {
+	if l.peekChar() != next {
+		return newToken(oneChar, l.ch)
+	}
+
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: twoChar, Literal: string(ch) + string(l.ch)}
+}
+
+func isHexDigit(ch byte) bool { // helper function
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func hexValue(ch byte) int { // helper function
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	default:
+		return int(ch-'A') + 10
+	}
 }
@@ -12,7 +12,7 @@ func TestMoreNextTokenAdvanced(t *testing.T) {
 		x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 	
 	if (5 < 10) {
@@ -130,7 +130,7 @@ let add = func(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -279,3 +279,345 @@ macro(x, y) { x + y; };
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  \"ab\" ==\n}"
+
+	tests := []struct {
+		expectedType token.TokenType
+		startLine    int
+		startColumn  int
+		endLine      int
+		endColumn    int
+	}{
+		{token.LET, 1, 1, 1, 4},
+		{token.IDENT, 1, 5, 1, 6},
+		{token.ASSIGN, 1, 7, 1, 8},
+		{token.INT, 1, 9, 1, 10},
+		{token.SEMICOLON, 1, 10, 1, 11},
+		{token.STRING, 2, 3, 2, 7},
+		{token.EQ, 2, 8, 2, 10},
+		{token.RBRACE, 3, 1, 3, 2},
+		{token.EOF, 3, 2, 3, 3},
+	}
+
+	l := NewWithFilename("test.junk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Filename != "test.junk" {
+			t.Fatalf("tests[%d] - filename wrong. got=%q", i, tok.Pos.Filename)
+		}
+
+		if tok.Pos.Line != tt.startLine || tok.Pos.Column != tt.startColumn {
+			t.Fatalf("tests[%d] - start position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.startLine, tt.startColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.End.Line != tt.endLine || tok.End.Column != tt.endColumn {
+			t.Fatalf("tests[%d] - end position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.endLine, tt.endColumn, tok.End.Line, tok.End.Column)
+		}
+	}
+}
+
+func TestShebangLine(t *testing.T) {
+	input := "#!/usr/bin/env junk\nlet x = 1;"
+
+	l := New(input)
+	tok := l.NextToken()
+
+	if tok.Type != token.LET {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.LET, tok.Type)
+	}
+
+	if tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+		t.Fatalf("position wrong. expected=2:1, got=%d:%d", tok.Pos.Line, tok.Pos.Column)
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `5 3.14 1e-9 2.5E+3 7e 1.x 10.`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "7"},
+		{token.IDENT, "e"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.INT, "10"},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `# a hash comment
+let x = 1; // a line comment
+/* a block
+   comment */ x / 2;
+x // trailing`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	input := "# one\nx /* two */ // three"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line            int
+		column          int
+	}{
+		{token.COMMENT, "# one", 1, 1},
+		{token.IDENT, "x", 2, 1},
+		{token.COMMENT, "/* two */", 2, 3},
+		{token.COMMENT, "// three", 2, 13},
+		{token.EOF, "", 2, 21},
+	}
+
+	l := New(input)
+	l.SetKeepComments(true)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.line || tok.Pos.Column != tt.column {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.line, tt.column, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("1 /* never closed")
+
+	l.NextToken()
+	tok := l.NextToken()
+
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.ILLEGAL, tok.Type)
+	}
+	if tok.Literal != "/* never closed" {
+		t.Fatalf("literal wrong. got=%q", tok.Literal)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"\r\0"`, "\r\x00"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\x41\x62"`, "Ab"},
+		{`"café"`, "café"},
+		{`"\u{1F600}"`, "\U0001F600"},
+		{"`raw \\n string`", `raw \n string`},
+		{"`two\nlines`", "two\nlines"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Errorf("%s: tokentype wrong. expected=%q, got=%q", tt.input, token.STRING, tok.Type)
+			continue
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("%s: literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("%s: unexpected errors: %v", tt.input, l.Errors())
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedMessage string
+		expectedColumn  int
+	}{
+		{`"bad \q"`, token.STRING, "unknown escape sequence: \\q", 6},
+		{`"\x4"`, token.STRING, "invalid escape sequence: \\x must be followed by 2 hex digits", 2},
+		{`"\u12"`, token.STRING, "invalid unicode escape sequence", 2},
+		{`"\u{110000}"`, token.STRING, "invalid unicode escape sequence", 2},
+		{`"never closed`, token.ILLEGAL, "unterminated string", 1},
+		{"\"ends at\nnewline\"", token.ILLEGAL, "unterminated string", 1},
+		{"`never closed", token.ILLEGAL, "unterminated raw string", 1},
+		{"@", token.ILLEGAL, "illegal character '@'", 1},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q: tokentype wrong. expected=%q, got=%q", tt.input, tt.expectedType, tok.Type)
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q: expected 1 error, got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%q: message wrong. expected=%q, got=%q", tt.input, tt.expectedMessage, errors[0].Message)
+		}
+		if errors[0].Pos.Column != tt.expectedColumn {
+			t.Errorf("%q: column wrong. expected=%d, got=%d", tt.input, tt.expectedColumn, errors[0].Pos.Column)
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := `a <= b >= c % d && e || f < g > h
+x = 1; x += 1; x -= 1; x *= 1; x /= 1; x %= 1;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.AND, "&&"},
+		{token.IDENT, "e"},
+		{token.OR, "||"},
+		{token.IDENT, "f"},
+		{token.LT, "<"},
+		{token.IDENT, "g"},
+		{token.GT, ">"},
+		{token.IDENT, "h"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	l = New("a & b")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Fatalf("single & should be illegal. got=%q", tok.Type)
+	}
+	if len(l.Errors()) != 1 || l.Errors()[0].Message != "illegal character '&', did you mean &&?" {
+		t.Fatalf("wrong errors. got=%v", l.Errors())
+	}
+
+	l = New("...rest")
+	if tok := l.NextToken(); tok.Type != token.ELLIPSIS || tok.Literal != "..." {
+		t.Fatalf("wrong token. expected ELLIPSIS, got=%q %q", tok.Type, tok.Literal)
+	}
+
+	l = New("a.b")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+		t.Fatalf("single . should be illegal. got=%q", tok.Type)
+	}
+}
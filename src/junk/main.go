@@ -2,17 +2,172 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"junk/evaluator"
+	"junk/object"
 	"junk/repl"
 	"os"
 	"os/user"
+	"strings"
 )
 
+// Exit codes reported by the junk command.
+const (
+	exitOK           = 0
+	exitRuntimeError = 1
+	exitParseError   = 2
+	exitUsage        = 64
+	exitNoInput      = 66
+)
+
+const usage = `usage:
+  junk [OPTIONS]                       start the REPL (or run stdin when it is not a terminal)
+  junk [OPTIONS] run FILE              run the script in FILE
+  junk [OPTIONS] FILE                  same as junk run FILE
+  junk [OPTIONS] -e EXPR               evaluate EXPR and print the result
+  junk -h                              show this help
+
+options:
+  --engine NAME   execution backend, see below
+  --checked       report int64 overflow as an error instead of promoting to a big integer
+
+engines:
+  eval   tree-walking evaluator (default)
+  vm     bytecode compiler and virtual machine
+`
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// config holds the options given on the command line.
+type config struct {
+	engine  string // name of the execution backend
+	checked bool   // report int64 overflow as an error
+}
+
+func run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	cfg := config{engine: repl.ENGINE_EVAL}
+
+	for len(args) > 0 && (args[0] == "--checked" || strings.HasPrefix(args[0], "--engine")) {
+		if args[0] == "--checked" {
+			cfg.checked = true
+			args = args[1:]
+		} else if name, ok := strings.CutPrefix(args[0], "--engine="); ok {
+			cfg.engine = name
+			args = args[1:]
+		} else if args[0] == "--engine" && len(args) > 1 {
+			cfg.engine = args[1]
+			args = args[2:]
+		} else {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+	}
+
+	if _, err := repl.NewEngine(cfg.engine, repl.EngineOptions{}); err != nil {
+		fmt.Fprintf(stderr, "junk: %s\n", err)
+		return exitUsage
+	}
+
+	if len(args) == 0 {
+		if !isTerminal(stdin) {
+			src, err := io.ReadAll(stdin)
+			if err != nil {
+				fmt.Fprintf(stderr, "junk: %s\n", err)
+				return exitRuntimeError
+			}
+			return runSource(cfg, "<stdin>", string(src), false, stdin, stdout, stderr)
+		}
+
+		startRepl(cfg, stdin, stdout)
+		return exitOK
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	case "-e":
+		if len(args) != 2 {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		return runSource(cfg, "<expr>", args[1], true, stdin, stdout, stderr)
+	case "run":
+		if len(args) != 2 {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		return runFile(cfg, args[1], stdin, stdout, stderr)
+	default:
+		if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+			fmt.Fprint(stderr, usage)
+			return exitUsage
+		}
+		return runFile(cfg, args[0], stdin, stdout, stderr)
+	}
+}
+
+func startRepl(cfg config, in io.Reader, out io.Writer) {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
-	fmt.Printf("Hello %s! This is the junk programming language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Fprintf(out, "Hello %s! This is the junk programming language!\n", user.Username)
+	fmt.Fprintf(out, "Feel free to type in commands\n")
+	repl.StartWithEngine(in, out, cfg.engine, cfg.checked)
+}
+
+func runFile(cfg config, filename string, stdin io.Reader, stdout, stderr io.Writer) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "junk: %s\n", err)
+		return exitNoInput
+	}
+
+	return runSource(cfg, filename, string(src), false, stdin, stdout, stderr)
+}
+
+// runSource evaluates a whole program and maps the outcome to an exit code.
+// When printResult is set the value of the program is written to stdout.
+// The builtins of the program use stdin, stdout and stderr.
+func runSource(cfg config, filename, src string, printResult bool, stdin io.Reader, stdout, stderr io.Writer) int {
+	streams := evaluator.IO{Stdout: stdout, Stderr: stderr, Stdin: stdin}
+
+	engine, err := repl.NewEngine(cfg.engine, repl.EngineOptions{Streams: streams, CheckedArithmetic: cfg.checked})
+	if err != nil {
+		fmt.Fprintf(stderr, "junk: %s\n", err)
+		return exitUsage
+	}
+
+	macroEnv := object.NewEnvironmentWithBuiltins(evaluator.NewBuiltins(streams))
+	macroEnv.SetCheckedArithmetic(cfg.checked)
+	evaluated, errors := repl.Run(filename, src, engine, macroEnv)
+	if len(errors) != 0 {
+		for _, msg := range errors {
+			fmt.Fprintln(stderr, msg)
+		}
+		return exitParseError
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintln(stderr, errObj.Inspect())
+		io.WriteString(stderr, errObj.StackTrace())
+		return exitRuntimeError
+	}
+
+	if printResult && evaluated != nil && evaluated.Type() != object.NULL_OBJ {
+		fmt.Fprintln(stdout, evaluated.Inspect())
+	}
+
+	return exitOK
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
 }
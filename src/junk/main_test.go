@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stdinWith returns a file to use as the stdin of run, holding input.
+func stdinWith(t *testing.T, input string) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("cannot write stdin: %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("cannot open stdin: %s", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestRun(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.junk")
+	if err := os.WriteFile(script, []byte(`puts("from file")`), 0o644); err != nil {
+		t.Fatalf("cannot write script: %s", err)
+	}
+
+	tests := []struct {
+		args     []string
+		stdin    string
+		exitCode int
+		stdout   string
+		stderr   string
+	}{
+		{args: []string{"-e", "1 + 2"}, exitCode: exitOK, stdout: "3\n"},
+		{args: []string{"--engine", "vm", "-e", "1 + 2"}, exitCode: exitOK, stdout: "3\n"},
+		{args: []string{"--engine=vm", "-e", "1 + 2"}, exitCode: exitOK, stdout: "3\n"},
+		{args: []string{"-e", "9223372036854775807 + 1"}, exitCode: exitOK, stdout: "9223372036854775808\n"},
+		{args: []string{"--checked", "-e", "9223372036854775807 + 1"}, exitCode: exitRuntimeError, stderr: "ERROR: integer overflow"},
+		{args: []string{"--checked", "--engine=vm", "-e", "9223372036854775807 + 1"}, exitCode: exitRuntimeError, stderr: "ERROR: integer overflow"},
+		{args: []string{"-e", "1 + true"}, exitCode: exitRuntimeError, stderr: "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{args: []string{"-e", "let = 1"}, exitCode: exitParseError, stderr: "expected next token to be IDENT"},
+		{args: []string{script}, exitCode: exitOK, stdout: "from file\n"},
+		{args: []string{"run", script}, exitCode: exitOK, stdout: "from file\n"},
+		{args: []string{}, stdin: "puts(6 * 7)", exitCode: exitOK, stdout: "42\n"},
+		{args: []string{"-h"}, exitCode: exitOK, stdout: "usage:"},
+		{args: []string{"missing.junk"}, exitCode: exitNoInput, stderr: "junk: open missing.junk"},
+		{args: []string{""}, exitCode: exitNoInput, stderr: "junk: open"},
+		{args: []string{"-x"}, exitCode: exitUsage, stderr: "usage:"},
+		{args: []string{"-e"}, exitCode: exitUsage, stderr: "usage:"},
+		{args: []string{"run"}, exitCode: exitUsage, stderr: "usage:"},
+		{args: []string{"a.junk", "b.junk"}, exitCode: exitUsage, stderr: "usage:"},
+		{args: []string{"--engine"}, exitCode: exitUsage, stderr: "usage:"},
+		{args: []string{"--engine=jit", "-e", "1"}, exitCode: exitUsage, stderr: `unknown engine "jit"`},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		exitCode := run(tt.args, stdinWith(t, tt.stdin), &stdout, &stderr)
+
+		if exitCode != tt.exitCode {
+			t.Errorf("%q: wrong exit code. want=%d, got=%d (stderr %q)", tt.args, tt.exitCode, exitCode, stderr.String())
+		}
+		if !strings.HasPrefix(stdout.String(), tt.stdout) || (tt.stdout == "" && stdout.Len() != 0) {
+			t.Errorf("%q: wrong stdout. want=%q, got=%q", tt.args, tt.stdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.stderr) || (tt.stderr == "" && stderr.Len() != 0) {
+			t.Errorf("%q: wrong stderr. want=%q, got=%q", tt.args, tt.stderr, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package object
+
+import "context"
+
+// Limits bounds the resources a program may use. A zero field means no
+// limit, except for MaxCallDepth which then falls back to a default that
+// keeps deep recursion from exhausting the Go stack.
+type Limits struct {
+	MaxSteps       int64 // evaluation steps, one per evaluated node
+	MaxCallDepth   int   // nested function calls
+	MaxAllocations int64 // objects and environments created; an array or hash also counts its elements, a string every 64 bytes
+}
+
+// Budget tracks what a running program has used of its limits, and the
+// context that may cancel it.
+type Budget struct {
+	Context context.Context
+	Limits  Limits
+
+	Steps       int64
+	Allocations int64
+
+	// Caller is the call that is running a builtin, or nil. Functions that
+	// the builtin calls back are nested in it.
+	Caller *Frame
+}
+
+// NewBudget returns a budget for a program that stops when ctx is done or
+// one of limits is reached.
+func NewBudget(ctx context.Context, limits Limits) *Budget {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Budget{Context: ctx, Limits: limits}
+}
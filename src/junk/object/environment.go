@@ -1,19 +1,111 @@
 package object
 
+import (
+	"junk/token"
+	"sort"
+)
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
 	return env
 }
 
+// NewCallEnvironment creates the environment of a function call, described
+// by frame.
+func NewCallEnvironment(outer *Environment, frame *Frame) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.frame = frame
+	return env
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s, outer: nil}
 }
 
+// NewEnvironmentWithBuiltins creates a global environment whose programs
+// call builtins instead of the standard builtins of the evaluator.
+func NewEnvironmentWithBuiltins(builtins map[string]*Builtin) *Environment {
+	env := NewEnvironment()
+	env.builtins = builtins
+	return env
+}
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	outer    *Environment
+	frame    *Frame              // set on the environment of a function call
+	builtins map[string]*Builtin // set on a global environment with its own builtins
+	budget   *Budget             // set while a program with limits runs in the environment
+	checked  bool                // set on a global environment with checked arithmetic
+}
+
+// SetBudget makes b track the programs running in e and the environments
+// enclosed by it. It returns the budget it replaces.
+func (e *Environment) SetBudget(b *Budget) *Budget {
+	old := e.budget
+	e.budget = b
+	return old
+}
+
+// Budget returns the budget of the innermost environment around e that
+// has one, or nil.
+func (e *Environment) Budget() *Budget {
+	for env := e; env != nil; env = env.outer {
+		if env.budget != nil {
+			return env.budget
+		}
+	}
+	return nil
+}
+
+// Builtins returns the builtins of the global environment that e belongs
+// to, or nil when it uses the standard ones.
+func (e *Environment) Builtins() map[string]*Builtin {
+	for env := e; env != nil; env = env.outer {
+		if env.builtins != nil {
+			return env.builtins
+		}
+	}
+	return nil
+}
+
+// SetCheckedArithmetic makes integer arithmetic in e and the environments
+// enclosed by it report an error when the result does not fit in an int64,
+// instead of promoting it to a big integer.
+func (e *Environment) SetCheckedArithmetic(checked bool) {
+	e.checked = checked
+}
+
+// CheckedArithmetic reports whether integer overflow is an error in e, see
+// SetCheckedArithmetic.
+func (e *Environment) CheckedArithmetic() bool {
+	for env := e; env != nil; env = env.outer {
+		if env.checked {
+			return true
+		}
+	}
+	return false
+}
+
+// Frame is an active function call, linked to the frame of its caller.
+type Frame struct {
+	Function string         // name the function was bound to, or ""
+	CallPos  token.Position // where the function was called
+	Caller   *Frame         // nil when called from the main program
+	Depth    int            // number of active calls, 1 for a call from the main program
+}
+
+// Frame returns the innermost function call that e belongs to, or nil in
+// the main program.
+func (e *Environment) Frame() *Frame {
+	for env := e; env != nil; env = env.outer {
+		if env.frame != nil {
+			return env.frame
+		}
+	}
+	return nil
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +120,40 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Assign updates name in the innermost environment that defines it. It
+// reports false when name is not defined anywhere.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// Copy returns a new environment with the same bindings and the same outer
+// environment. Later changes to either one are not seen by the other.
+func (e *Environment) Copy() *Environment {
+	env := NewEnclosedEnvironment(e.outer)
+	env.builtins = e.builtins
+	env.budget = e.budget
+	env.checked = e.checked
+	for name, val := range e.store {
+		env.store[name] = val
+	}
+	return env
+}
+
+// Names returns the sorted names bound directly in this environment,
+// without looking at outer environments.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"hash/fnv"
 	"junk/ast"
+	"junk/code"
+	"junk/token"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -12,9 +17,13 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
@@ -23,6 +32,8 @@ const (
 	HASH_OBJ         = "HASH"
 	QUOTE_OBJ        = "QUOTE"
 	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -37,6 +48,29 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// BigInt is an integer that does not fit in an int64. Arithmetic on integers
+// promotes its result to a BigInt when it overflows, and demotes it back to
+// an Integer when it fits again.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") { // keep "3.0" distinguishable from 3
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -56,15 +90,69 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue signal a break or continue statement to the loop that
+// is being evaluated, the same way ReturnValue signals a return.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     token.Position // where the error happened
+	Frame   *Frame         // the function call it happened in
+	Cause   error          // Go error behind the error, e.g. a hit limit, or nil
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
+// stackTraceEnds is the number of calls shown at each end of a long stack
+// trace; the calls in between are left out.
+const stackTraceEnds = 10
+
+// StackTrace lists the calls that were active when the error happened,
+// innermost first, e.g. "  at add (test.junk:2:3)". It is empty when the
+// error has no position.
+func (e *Error) StackTrace() string {
+	if !e.Pos.IsValid() {
+		return ""
+	}
+
+	var lines []string
+
+	pos := e.Pos
+	for frame := e.Frame; frame != nil; frame = frame.Caller {
+		name := frame.Function
+		if name == "" {
+			name = "<anonymous>"
+		}
+		lines = append(lines, fmt.Sprintf("  at %s (%s)\n", name, pos))
+		pos = frame.CallPos
+	}
+	lines = append(lines, fmt.Sprintf("  at <main> (%s)\n", pos))
+
+	if len(lines) > 2*stackTraceEnds+1 {
+		skipped := len(lines) - 2*stackTraceEnds
+		tail := lines[len(lines)-stackTraceEnds:]
+		lines = append(lines[:stackTraceEnds:stackTraceEnds],
+			fmt.Sprintf("  ... %d more calls ...\n", skipped))
+		lines = append(lines, tail...)
+	}
+
+	return strings.Join(lines, "")
+}
+
 type Function struct {
+	Name       string // name the function was bound to with let, or ""
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default value of each parameter, nil for a required one
+	Rest       *ast.Identifier  // parameter collecting the remaining arguments, or nil
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -73,14 +161,9 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParameterList(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -109,12 +192,14 @@ type Array struct {
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
+func (ao *Array) Inspect() string  { return inspect(ao, nil) }
+
+func (ao *Array) inspect(seen map[Object]bool) string {
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspect(e, seen))
 	}
 
 	out.WriteString("[")
@@ -144,6 +229,27 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// HashKey returns the key of the equal integer when f has an integral value,
+// so that 1.0 finds the value stored under 1, and 0.0 the one under -0.0.
+func (f *Float) HashKey() HashKey {
+	switch {
+	case math.IsInf(f.Value, 0) || f.Value != math.Trunc(f.Value):
+		return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+	case f.Value >= math.MinInt64 && f.Value < math.MaxInt64:
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	default:
+		value, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInt{Value: value}).HashKey()
+	}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -156,17 +262,71 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps hashable keys to values and keeps its pairs in the order their
+// keys were first set. The zero value is an empty hash.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs []HashPair
+	index map[HashKey]int // position of each key in pairs
+}
+
+// Len returns the number of pairs in h.
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Pairs returns the pairs of h in insertion order. The slice belongs to h
+// and must not be modified.
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+// Get returns the pair stored under key.
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	i, ok := h.index[key]
+	if !ok {
+		return HashPair{}, false
+	}
+	return h.pairs[i], true
+}
+
+// Set stores pair under key. A key that is already present keeps its
+// position.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if i, ok := h.index[key]; ok {
+		h.pairs[i] = pair
+		return
+	}
+
+	if h.index == nil {
+		h.index = make(map[HashKey]int)
+	}
+	h.index[key] = len(h.pairs)
+	h.pairs = append(h.pairs, pair)
+}
+
+// Delete removes the pair stored under key and reports whether there was
+// one.
+func (h *Hash) Delete(key HashKey) bool {
+	i, ok := h.index[key]
+	if !ok {
+		return false
+	}
+
+	delete(h.index, key)
+	h.pairs = append(h.pairs[:i:i], h.pairs[i+1:]...) // a copy, so slices from Pairs stay intact
+	for k, j := range h.index {
+		if j > i {
+			h.index[k] = j - 1
+		}
+	}
+	return true
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string  { return inspect(h, nil) }
+
+func (h *Hash) inspect(seen map[Object]bool) string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	for _, pair := range h.pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspect(pair.Value, seen)))
 	}
 
 	out.WriteString("{")
@@ -176,6 +336,38 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// inspect returns the Inspect form of obj. seen holds the arrays and hashes
+// being printed further up; one that contains itself prints as [...] or
+// {...} where it comes round again, instead of recursing forever.
+func inspect(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen = markSeen(seen, obj)
+		defer delete(seen, obj)
+		return obj.inspect(seen)
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen = markSeen(seen, obj)
+		defer delete(seen, obj)
+		return obj.inspect(seen)
+	default:
+		return obj.Inspect()
+	}
+}
+
+func markSeen(seen map[Object]bool, obj Object) map[Object]bool {
+	if seen == nil {
+		seen = map[Object]bool{}
+	}
+	seen[obj] = true
+	return seen
+}
+
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -213,3 +405,28 @@ func (m *Macro) Inspect() string {
 
 	return out.String()
 }
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int  // named parameters, without the rest parameter
+	NumDefaults   int  // trailing parameters that have a default value
+	Variadic      bool // whether a rest parameter follows the named ones
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is how the virtual machine represents a function value, so it
+// reports the same type as a Function does in the evaluator.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"junk/token"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +26,165 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestBigIntHashKey(t *testing.T) {
+	big1, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	big2, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	diff, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+
+	if (&BigInt{Value: big1}).HashKey() != (&BigInt{Value: big2}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+
+	if (&BigInt{Value: big1}).HashKey() == (&BigInt{Value: diff}).HashKey() {
+		t.Errorf("big integers with different values have same hash keys")
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	tests := []struct {
+		float *Float
+		key   Hashable
+		equal bool
+	}{
+		{&Float{Value: 1}, &Integer{Value: 1}, true},
+		{&Float{Value: math.Copysign(0, -1)}, &Integer{Value: 0}, true},
+		{&Float{Value: -9223372036854775808}, &Integer{Value: math.MinInt64}, true},
+		{&Float{Value: 1e20}, &BigInt{Value: huge}, true},
+		{&Float{Value: 1.5}, &Float{Value: 1.5}, true},
+		{&Float{Value: 1.5}, &Integer{Value: 1}, false},
+		{&Float{Value: math.Inf(1)}, &Float{Value: math.Inf(1)}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.float.HashKey() == tt.key.HashKey(); got != tt.equal {
+			t.Errorf("%s and %s: hash keys equal=%t, want %t", tt.float.Inspect(), tt.key.(Object).Inspect(), got, tt.equal)
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := &Hash{}
+	for i, key := range []string{"c", "a", "b"} {
+		str := &String{Value: key}
+		hash.Set(str.HashKey(), HashPair{Key: str, Value: &Integer{Value: int64(i)}})
+	}
+
+	if hash.Inspect() != "{c: 0, a: 1, b: 2}" {
+		t.Errorf("pairs not in insertion order. got=%s", hash.Inspect())
+	}
+
+	a := &String{Value: "a"}
+	hash.Set(a.HashKey(), HashPair{Key: a, Value: &Integer{Value: 10}})
+	if hash.Inspect() != "{c: 0, a: 10, b: 2}" {
+		t.Errorf("updated key moved. got=%s", hash.Inspect())
+	}
+
+	pairs := hash.Pairs()
+	if !hash.Delete((&String{Value: "c"}).HashKey()) {
+		t.Fatalf("Delete did not find c")
+	}
+	if hash.Delete((&String{Value: "c"}).HashKey()) {
+		t.Errorf("Delete found c twice")
+	}
+	if hash.Inspect() != "{a: 10, b: 2}" || hash.Len() != 2 {
+		t.Errorf("wrong pairs after Delete. got=%s", hash.Inspect())
+	}
+	if len(pairs) != 3 || pairs[0].Key.Inspect() != "c" {
+		t.Errorf("Delete changed an earlier result of Pairs. got=%v", pairs)
+	}
+
+	pair, ok := hash.Get((&String{Value: "b"}).HashKey())
+	if !ok || pair.Value.Inspect() != "2" {
+		t.Errorf("wrong pair for b after Delete. got=%v, %t", pair, ok)
+	}
+
+	d := &String{Value: "d"}
+	hash.Set(d.HashKey(), HashPair{Key: d, Value: &Integer{Value: 3}})
+	hash.Set(a.HashKey(), HashPair{Key: a, Value: &Integer{Value: 11}})
+	if hash.Inspect() != "{a: 11, b: 2, d: 3}" {
+		t.Errorf("wrong pairs after Set. got=%s", hash.Inspect())
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{3, "3.0"},
+		{-0.5, "-0.5"},
+		{1e-9, "1e-09"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if !inner.Assign("x", &Integer{Value: 2}) {
+		t.Fatalf("assigning to a variable of the outer environment failed")
+	}
+	if len(inner.Names()) != 0 {
+		t.Errorf("assignment created a binding in the inner environment: %v", inner.Names())
+	}
+	if val, _ := outer.Get("x"); val.Inspect() != "2" {
+		t.Errorf("outer x has wrong value. got=%s", val.Inspect())
+	}
+
+	if inner.Assign("y", &Integer{Value: 3}) {
+		t.Errorf("assigning to an undefined variable succeeded")
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	if trace := (&Error{Message: "boom"}).StackTrace(); trace != "" {
+		t.Errorf("error without position has stack trace %q", trace)
+	}
+
+	caller := &Frame{Function: "f", CallPos: token.Position{Filename: "a.junk", Line: 3, Column: 1}}
+	anon := &Frame{CallPos: token.Position{Filename: "a.junk", Line: 2, Column: 5}, Caller: caller}
+	err := &Error{
+		Message: "boom",
+		Pos:     token.Position{Filename: "a.junk", Line: 1, Column: 9},
+		Frame:   anon,
+	}
+
+	expected := "  at <anonymous> (a.junk:1:9)\n" +
+		"  at f (a.junk:2:5)\n" +
+		"  at <main> (a.junk:3:1)\n"
+	if trace := err.StackTrace(); trace != expected {
+		t.Errorf("wrong stack trace. expected=%q, got=%q", expected, trace)
+	}
+}
+
+func TestLongStackTrace(t *testing.T) {
+	var frame *Frame
+	for i := 1; i <= 100; i++ {
+		frame = &Frame{Function: "f", CallPos: token.Position{Line: i, Column: 1}, Caller: frame, Depth: i}
+	}
+	err := &Error{Message: "boom", Pos: token.Position{Line: 101, Column: 1}, Frame: frame}
+
+	lines := strings.Split(strings.TrimSuffix(err.StackTrace(), "\n"), "\n")
+	if len(lines) != 2*stackTraceEnds+1 {
+		t.Fatalf("wrong number of lines. got=%d", len(lines))
+	}
+	if lines[stackTraceEnds] != "  ... 81 more calls ..." {
+		t.Errorf("wrong elision line. got=%q", lines[stackTraceEnds])
+	}
+	if lines[len(lines)-1] != "  at <main> (1:1)" {
+		t.Errorf("wrong last line. got=%q", lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"junk/token"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic is a single problem found while parsing, located in the source.
+type Diagnostic struct {
+	Pos      token.Position  // where the problem starts
+	End      token.Position  // where the problem ends
+	Message  string          // human readable description
+	Expected token.TokenType // the token the parser wanted, if any
+	Actual   token.Token     // the token the parser found
+	Severity Severity
+}
+
+func (d Diagnostic) String() string {
+	if !d.Pos.IsValid() {
+		return d.Message
+	}
+	return d.Pos.String() + ": " + d.Message
+}
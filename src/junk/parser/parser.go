@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"junk/ast"
 	"junk/lexer"
 	"junk/token"
+	"math/big"
 	"strconv"
 )
 
@@ -16,6 +18,9 @@ type (
 const (
 	_ int = iota // ignore first value by assigning to blank identifier
 	LOWEST
+	ASSIGN      // = or +=
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -26,8 +31,10 @@ const (
 )
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l           *lexer.Lexer
+	diagnostics []Diagnostic
+	panicking   bool // set after an error until the parser resynchronises
+	lexerErrors int  // number of lexer errors already turned into diagnostics
 
 	curToken  token.Token
 	peekToken token.Token
@@ -37,27 +44,39 @@ type Parser struct {
 }
 
 var precedences = map[token.TokenType]int{ // map of precedences
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []Diagnostic{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn) // initialize map
 	p.registerPrefix(token.IDENT, p.parseIdentifier)           // register identifier parse function
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)         // register integer literal parse function
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)         // register float literal parse function
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)      // register prefix expression parse function
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)     // register prefix expression parse function
 	p.registerPrefix(token.TRUE, p.parseBoolean)               // register boolean parse function
@@ -70,17 +89,28 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)         // register hash literal parse function
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)         // register macro literal parse function
 
-	p.infixParseFns = make(map[token.TokenType]infixParseFn) // initialize map
-	p.registerInfix(token.PLUS, p.parseInfixExpression)      // register infix expression parse function
-	p.registerInfix(token.MINUS, p.parseInfixExpression)     // register infix expression parse function
-	p.registerInfix(token.SLASH, p.parseInfixExpression)     // register infix expression parse function
-	p.registerInfix(token.ASTERISK, p.parseInfixExpression)  // register infix expression parse function
-	p.registerInfix(token.EQ, p.parseInfixExpression)        // register infix expression parse function
-	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)    // register infix expression parse function
-	p.registerInfix(token.LT, p.parseInfixExpression)        // register infix expression parse function
-	p.registerInfix(token.GT, p.parseInfixExpression)        // register infix expression parse function
-	p.registerInfix(token.LPAREN, p.parseCallExpression)     // register call expression parse function
-	p.registerInfix(token.LBRACKET, p.parseIndexExpression)  // register index expression parse function
+	p.infixParseFns = make(map[token.TokenType]infixParseFn)        // initialize map
+	p.registerInfix(token.PLUS, p.parseInfixExpression)             // register infix expression parse function
+	p.registerInfix(token.MINUS, p.parseInfixExpression)            // register infix expression parse function
+	p.registerInfix(token.SLASH, p.parseInfixExpression)            // register infix expression parse function
+	p.registerInfix(token.ASTERISK, p.parseInfixExpression)         // register infix expression parse function
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)          // register infix expression parse function
+	p.registerInfix(token.EQ, p.parseInfixExpression)               // register infix expression parse function
+	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)           // register infix expression parse function
+	p.registerInfix(token.LT, p.parseInfixExpression)               // register infix expression parse function
+	p.registerInfix(token.GT, p.parseInfixExpression)               // register infix expression parse function
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)            // register infix expression parse function
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)            // register infix expression parse function
+	p.registerInfix(token.AND, p.parseInfixExpression)              // register infix expression parse function
+	p.registerInfix(token.OR, p.parseInfixExpression)               // register infix expression parse function
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)          // register assignment parse function
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)     // register assignment parse function
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)    // register assignment parse function
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression) // register assignment parse function
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)    // register assignment parse function
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)  // register assignment parse function
+	p.registerInfix(token.LPAREN, p.parseCallExpression)            // register call expression parse function
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)         // register index expression parse function
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -137,14 +167,27 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) { // check current token type
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) { // check current token type
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt) // append to slice
 		}
+		if p.panicking {
+			if p.curTokenIs(token.RBRACE) { // the error was at the end of this block
+				p.panicking = false
+				break
+			}
+			p.synchronize()
+		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.EOF) {
+		p.unexpectedTokenError(token.RBRACE, p.curToken)
+	}
+
+	block.Rbrace = p.curToken
+
 	return block
 }
 
@@ -167,7 +210,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters() // parse function parameters
+	lit.Parameters, lit.Defaults, lit.Rest = p.parseFunctionParameters() // parse function parameters
 
 	if !p.expectPeek(token.LBRACE) { // check next token type
 		return nil
@@ -185,7 +228,13 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters() // parse function parameters
+	tok := p.peekToken
+	params, defaults, rest := p.parseFunctionParameters() // parse function parameters
+	if defaults != nil || rest != nil {
+		p.addError(tok, "", "macros cannot have default or rest parameters")
+		return nil
+	}
+	lit.Parameters = params
 
 	if !p.expectPeek(token.LBRACE) { // check next token type
 		return nil
@@ -196,36 +245,71 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses a parameter list such as "(a, b = 2,
+// ...rest)". Required parameters come first, then those with a default
+// value, then an optional rest parameter. defaults is nil when no parameter
+// has a default value.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Expression, *ast.Identifier) {
 	identifiers := []*ast.Identifier{}
+	var defaults []ast.Expression
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
-	}
+		return identifiers, nil, nil
+	}
+
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil, nil, nil
+			}
+			rest := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.peekTokenIs(token.RPAREN) {
+				p.addError(p.peekToken, token.RPAREN, "rest parameter must be the last parameter")
+				return nil, nil, nil
+			}
+			p.nextToken()
+			return identifiers, defaults, rest
+		}
 
-	p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil, nil, nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if defaults == nil {
+				defaults = make([]ast.Expression, len(identifiers))
+			}
+			defaults = append(defaults, p.parseExpression(LOWEST))
+		} else if defaults != nil {
+			msg := fmt.Sprintf("parameter %s without default value follows a parameter with one", ident.Value)
+			p.addError(ident.Token, "", msg)
+			return nil, nil, nil
+		}
+		identifiers = append(identifiers, ident)
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil, nil
 	}
 
-	return identifiers
+	return identifiers, defaults, nil
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function} // initialize call expression
 	exp.Arguments = p.parseCallArguments()                            // parse expression list
+	exp.Rparen = p.curToken
 	return exp
 }
 
@@ -260,12 +344,13 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}          // initialize array literal
 	array.Elements = p.parseExpressionList(token.RBRACKET) // parse expression list
+	array.Rbracket = p.curToken
 	return array
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken} // initialize hash literal
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
 
 	for !p.peekTokenIs(token.RBRACE) { // check next token type
 		p.nextToken()
@@ -278,7 +363,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST) // parse expression
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) { // check next token type
 			return nil
@@ -289,6 +374,8 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
+	hash.Rbrace = p.curToken
+
 	return hash
 }
 
@@ -326,16 +413,49 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 
+	exp.Rbracket = p.curToken
+
 	return exp
 }
 
+// Errors returns the diagnostics formatted as plain strings.
 func (p *Parser) Errors() []string { // return errors
-	return p.errors
+	errors := make([]string, 0, len(p.diagnostics))
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.String())
+	}
+	return errors
+}
+
+func (p *Parser) Diagnostics() []Diagnostic { // return diagnostics
+	return p.diagnostics
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken() // read next token
+
+	for p.peekToken.Type == token.COMMENT { // comments are trivia to the parser
+		p.peekToken = p.l.NextToken()
+	}
+
+	p.addLexerErrors()
+}
+
+// addLexerErrors turns errors the lexer found while producing peekToken
+// into diagnostics. They are always recorded, even while panicking.
+func (p *Parser) addLexerErrors() {
+	errors := p.l.Errors()
+	for _, e := range errors[p.lexerErrors:] {
+		p.diagnostics = append(p.diagnostics, Diagnostic{
+			Pos:      e.Pos,
+			End:      p.peekToken.End,
+			Message:  e.Message,
+			Actual:   p.peekToken,
+			Severity: SeverityError,
+		})
+	}
+	p.lexerErrors = len(errors)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -347,6 +467,9 @@ func (p *Parser) ParseProgram() *ast.Program {
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt) // append to slice
 		}
+		if p.panicking {
+			p.synchronize()
+		}
 		p.nextToken()
 	}
 
@@ -356,11 +479,20 @@ func (p *Parser) ParseProgram() *ast.Program {
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type { // check current token type
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
 	case token.WHILE:
 		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
 		return p.parseExpressionStatement() // parse expression statement
 	}
@@ -388,10 +520,150 @@ func (p *Parser) parseWhileStatement() ast.Statement {
 
 	statement.Body = p.parseBlockStatement()
 
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check next token type
+		p.nextToken()
+	}
+
+	return statement
+}
+
+// parseForStatement parses both loop forms, `for (init; condition; update)`
+// and `for (x in iterable)`, telling them apart by the `in` or `,` that
+// follows the first identifier.
+func (p *Parser) parseForStatement() ast.Statement {
+	tok := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.IN) || p.peekTokenIs(token.COMMA)) {
+		return p.parseForInStatement(tok)
+	}
+
+	statement := &ast.ForStatement{Token: tok}
+
+	switch p.curToken.Type {
+	case token.SEMICOLON: // no init
+	case token.LET:
+		let := p.parseLetBinding()
+		if let == nil {
+			return nil
+		}
+		statement.Init = let
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	default:
+		statement.Init = &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		statement.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		statement.Update = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = p.parseBlockStatement()
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check next token type
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseForInStatement(tok token.Token) ast.Statement {
+	statement := &ast.ForInStatement{Token: tok}
+
+	statement.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		statement.Key = statement.Value
+		statement.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = p.parseBlockStatement()
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check next token type
+		p.nextToken()
+	}
+
 	return statement
 }
 
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check next token type
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check next token type
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement { // parse let statement
+	stmt := p.parseLetBinding()
+	if stmt == nil {
+		return nil
+	}
+
+	for !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check current token type
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLetBinding parses `let name = value` without the semicolons that
+// may follow it.
+func (p *Parser) parseLetBinding() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken} // initialize let statement
 
 	if !p.expectPeek(token.IDENT) { // check next token type
@@ -408,8 +680,8 @@ func (p *Parser) parseLetStatement() *ast.LetStatement { // parse let statement
 
 	stmt.Value = p.parseExpression(LOWEST) // parse expression
 
-	for p.peekTokenIs(token.SEMICOLON) { // check current token type
-		p.nextToken()
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
 	}
 
 	return stmt
@@ -422,7 +694,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement { // parse return s
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for p.peekTokenIs(token.SEMICOLON) { // check current token type
+	for !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check current token type
 		p.nextToken()
 	}
 
@@ -434,7 +706,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement { // parse
 
 	stmt.Expression = p.parseExpression(LOWEST) // parse expression
 
-	if p.peekTokenIs(token.SEMICOLON) { // check next token type
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // check next token type
 		p.nextToken()
 	}
 
@@ -467,9 +739,29 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "", msg)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, "", msg)
 		return nil
 	}
 
@@ -496,8 +788,66 @@ func (p *Parser) expectPeek(t token.TokenType) bool { // check next token type
 }
 
 func (p *Parser) peekError(t token.TokenType) { // add error
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.unexpectedTokenError(t, p.peekToken)
+}
+
+func (p *Parser) unexpectedTokenError(t token.TokenType, actual token.Token) {
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, actual.Type)
+	p.addError(actual, t, msg)
+}
+
+// addError records an error at tok. While the parser is panicking only the
+// first error is kept, so that one mistake does not cause a cascade.
+func (p *Parser) addError(tok token.Token, expected token.TokenType, msg string) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	if tok.Type == token.ILLEGAL { // the lexer has already reported it
+		return
+	}
+
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Message:  msg,
+		Expected: expected,
+		Actual:   tok,
+		Severity: SeverityError,
+	})
+}
+
+// synchronize skips tokens until the parser reaches a statement boundary:
+// a ';' or a token that is about to start a new statement or close the
+// enclosing block. Braces opened while skipping are skipped as a whole.
+func (p *Parser) synchronize() {
+	p.panicking = false
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE, token.RBRACE, token.EOF:
+				return
+			}
+		}
+
+		p.nextToken()
+	}
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -510,7 +860,7 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, "", msg)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -541,6 +891,33 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression parses `target = value` and the compound forms.
+// Assignment is right associative, so `a = b = 1` assigns 1 to both.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	if target == nil || p.panicking { // the target did not parse, its error is already reported
+		return nil
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		msg := fmt.Sprintf("cannot assign to %s", target.String())
+		p.addError(p.curToken, "", msg)
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1) // parse expression
+
+	return expression
+}
+
 func (p *Parser) peekPrecedence() int { // get precedence of next token
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"junk/ast"
 	"junk/lexer"
+	"junk/token"
 	"testing"
 )
 
@@ -219,6 +220,27 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. Got: %T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. Got: %v", literal.Big)
+	}
+	if literal.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.String() wrong. Got: %s", literal.String())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -299,6 +321,9 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
 		{"foobar - barfoo;", "foobar", "-", "barfoo"},
 		{"foobar * barfoo;", "foobar", "*", "barfoo"},
@@ -310,6 +335,8 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -445,6 +472,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a < b && c != d || !e",
+			"(((a < b) && (c != d)) || (!e))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -460,6 +503,107 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedOperator string
+		expectedString   string
+	}{
+		{"x = 5;", "=", "x = 5"},
+		{"x += y * 2;", "+=", "x += (y * 2)"},
+		{"x -= 1;", "-=", "x -= 1"},
+		{"x *= 2;", "*=", "x *= 2"},
+		{"x /= 2;", "/=", "x /= 2"},
+		{"x %= 2;", "%=", "x %= 2"},
+		{"x = y = 1;", "=", "x = y = 1"},
+		{"x = a || b;", "=", "x = (a || b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.expectedOperator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.expectedOperator, exp.Operator)
+		}
+		if !testIdentifier(t, exp.Target, "x") {
+			return
+		}
+		if exp.String() != tt.expectedString {
+			t.Errorf("exp.String() wrong. expected=%q, got=%q", tt.expectedString, exp.String())
+		}
+	}
+}
+
+func TestIndexAssignExpressionParsing(t *testing.T) {
+	input := `arr[i + 1] += 2 * 3;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+
+	target, ok := exp.Target.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp.Target is not ast.IndexExpression. got=%T", exp.Target)
+	}
+	if !testIdentifier(t, target.Left, "arr") {
+		return
+	}
+	if !testInfixExpression(t, target.Index, "i", "+", 1) {
+		return
+	}
+	if !testInfixExpression(t, exp.Value, 2, "*", 3) {
+		return
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2;", "1:3: cannot assign to 1"},
+		{"a + b = c;", "1:7: cannot assign to (a + b)"},
+		{"f() += 1;", "1:5: cannot assign to f()"},
+		// the target itself does not parse
+		{"func = 1;", "1:6: expected next token to be (, got = instead"},
+		{"if (x) = 1;", "1:8: expected next token to be {, got = instead"},
+		{"a + func = 1;", "1:10: expected next token to be (, got = instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong errors. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -741,6 +885,8 @@ func TestFunctionParameterParsing(t *testing.T) {
 		{input: "func() {};", expectedParams: []string{}},
 		{input: "func(x) {};", expectedParams: []string{"x"}},
 		{input: "func(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "func(x, y = 1) {};", expectedParams: []string{"x", "y"}},
+		{input: "func(x, ...rest) {};", expectedParams: []string{"x"}},
 	}
 
 	for _, tt := range tests {
@@ -763,6 +909,37 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	input := "func(a, b = a * 2, c = [], ...rest) { rest };"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function := stmt.Expression.(*ast.FunctionLiteral)
+
+	if len(function.Parameters) != 3 {
+		t.Fatalf("length parameters wrong. want 3, got=%d", len(function.Parameters))
+	}
+	if len(function.Defaults) != 3 || function.Defaults[0] != nil {
+		t.Fatalf("wrong defaults. got=%v", function.Defaults)
+	}
+	testInfixExpression(t, function.Defaults[1], "a", "*", 2)
+	if function.Defaults[2].String() != "[]" {
+		t.Errorf("wrong default for c. got=%q", function.Defaults[2].String())
+	}
+	if function.Rest == nil || function.Rest.Value != "rest" {
+		t.Fatalf("wrong rest parameter. got=%v", function.Rest)
+	}
+
+	expected := "func(a, b = (a * 2), c = [], ...rest) rest"
+	if function.String() != expected {
+		t.Errorf("wrong String(). want=%q, got=%q", expected, function.String())
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -935,20 +1112,19 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Fatalf("len(hash.Pairs) not 3. Got: %d", len(hash.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
-	}
+	expectedKeys := []string{"one", "two", "three"}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key not *ast.StringLiteral. Got: %T", key)
+			t.Errorf("key not *ast.StringLiteral. Got: %T", pair.Key)
+			continue
 		}
 
-		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		if literal.String() != expectedKeys[i] {
+			t.Errorf("pair %d has wrong key. want=%q, got=%q", i, expectedKeys[i], literal.String())
+		}
+		testIntegerLiteral(t, pair.Value, int64(i+1))
 	}
 }
 
@@ -1001,10 +1177,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key not *ast.StringLiteral. Got: %T", key)
+			t.Errorf("key not *ast.StringLiteral. Got: %T", pair.Key)
 		}
 
 		testFunc, ok := tests[literal.String()]
@@ -1012,7 +1188,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("No test function for key %q found", literal.String())
 		}
 
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 }
 
@@ -1039,3 +1215,276 @@ func TestWhileLoopStatementParsing(t *testing.T) {
 		t.Errorf("whileLoop.Body.Statements has not 0 statements. Got: %d", len(whileLoop.Body.Statements))
 	}
 }
+
+func TestForStatementParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < n; i += 1) { x }", "for (let i = 0; (i < n); i += 1) x"},
+		{"for (i = 0; i < n; i += 1) { x }", "for (i = 0; (i < n); i += 1) x"},
+		{"for (;;) { break; }", "for (; ; ) break;"},
+		{"for (; i < n;) { }", "for (; (i < n); ) "},
+		{"for (x in arr) { x }", "for (x in arr) x"},
+		{"for (k, v in {1: 2}) { v }", "for (k, v in {1:2}) v"},
+		{"for (x in [1, 2]) { x };", "for (x in [1, 2]) x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%q: program.String() wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("for (k, v in h) { }")).ParseProgram()
+	forIn, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ForInStatement. Got: %T", program.Statements[0])
+	}
+	testIdentifier(t, forIn.Key, "k")
+	testIdentifier(t, forIn.Value, "v")
+	testIdentifier(t, forIn.Iterable, "h")
+}
+
+func TestBreakAndContinueParsing(t *testing.T) {
+	input := `while (true) { break; continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	whileLoop, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.WhileStatement. Got: %T", program.Statements[0])
+	}
+	if len(whileLoop.Body.Statements) != 2 {
+		t.Fatalf("whileLoop.Body.Statements has not 2 statements. Got: %d", len(whileLoop.Body.Statements))
+	}
+	if _, ok := whileLoop.Body.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[0] is not *ast.BreakStatement. Got: %T", whileLoop.Body.Statements[0])
+	}
+	if _, ok := whileLoop.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not *ast.ContinueStatement. Got: %T", whileLoop.Body.Statements[1])
+	}
+	if program.String() != "while (true) break;continue;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let add = func(x, y) {\n  x + y;\n};\nadd(1, [2, 3][0])"
+
+	l := lexer.NewWithFilename("test.junk", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+		end      string
+	}{
+		{program.Statements[0], "test.junk:1:1", "test.junk:3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value, "test.junk:1:11", "test.junk:3:2"},
+		{program.Statements[1], "test.junk:4:1", "test.junk:4:18"},
+		{program, "test.junk:1:1", "test.junk:4:18"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expected {
+			t.Errorf("tests[%d] - Pos() wrong. expected=%q, got=%q",
+				i, tt.expected, tt.node.Pos().String())
+		}
+		if tt.node.End().String() != tt.end {
+			t.Errorf("tests[%d] - End() wrong. expected=%q, got=%q",
+				i, tt.end, tt.node.End().String())
+		}
+	}
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	index := call.Arguments[1]
+	if index.Pos().String() != "test.junk:4:8" || index.End().String() != "test.junk:4:17" {
+		t.Errorf("index expression position wrong. got=%s-%s", index.Pos(), index.End())
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = add(1, 2; let y = 3;",
+			[]string{"1:17: expected next token to be ), got ; instead"},
+		},
+		{
+			"let = 5; let y = ;\nlet z = 10;",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"1:18: no prefix parse function for ; found",
+			},
+		},
+		{
+			"let f = func(x) { x + }; let g = 1;",
+			[]string{"1:23: no prefix parse function for } found"},
+		},
+		{
+			"if (x { 1 }\nlet y = 2;",
+			[]string{"1:7: expected next token to be ), got { instead"},
+		},
+		{
+			"while (true) { let x = 1;",
+			[]string{"1:26: expected next token to be }, got EOF instead"},
+		},
+		{
+			"func(a = 1, b) {}",
+			[]string{"1:13: parameter b without default value follows a parameter with one"},
+		},
+		{
+			"func(...a, b) {}",
+			[]string{"1:10: rest parameter must be the last parameter"},
+		},
+		{
+			"macro(a = 1) { a }",
+			[]string{"1:7: macros cannot have default or rest parameters"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of errors. expected=%d, got=%d (%q)",
+				tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	l := lexer.NewWithFilename("test.junk", "let x = (1 + 2;")
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%d", len(diagnostics))
+	}
+
+	d := diagnostics[0]
+	if d.Severity != SeverityError {
+		t.Errorf("wrong severity. got=%s", d.Severity)
+	}
+	if d.Expected != token.RPAREN {
+		t.Errorf("wrong expected token. got=%q", d.Expected)
+	}
+	if d.Actual.Type != token.SEMICOLON {
+		t.Errorf("wrong actual token. got=%q", d.Actual.Type)
+	}
+	if d.Pos.String() != "test.junk:1:15" {
+		t.Errorf("wrong position. got=%q", d.Pos.String())
+	}
+}
+
+func TestLexerErrorDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let s = "a\qb"; let y = 1;`, []string{"1:11: unknown escape sequence: \\q"}},
+		{`let s = "never closed`, []string{"1:9: unterminated string"}},
+		{"let x = 1 @ 2;\nlet y = 2;", []string{"1:11: illegal character '@'"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong number of errors. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("%q: error %d wrong. expected=%q, got=%q", tt.input, i, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E+3;", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestParsingSkipsComments(t *testing.T) {
+	input := "let x = 1; // one\n/* two */ let y = x # three\n;"
+
+	l := lexer.New(input)
+	l.SetKeepComments(true)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	if program.String() != "let x = 1;let y = x;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
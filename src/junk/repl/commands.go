@@ -0,0 +1,171 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"junk/evaluator"
+	"junk/lexer"
+	"junk/object"
+	"junk/parser"
+	"junk/token"
+	"os"
+	"strings"
+	"time"
+)
+
+const COMMANDS_HELP = `meta-commands:
+  :ast CODE      print the parsed program
+  :tokens CODE   print the tokens produced by the lexer
+  :env           list the bindings in the environment and the macro environment
+  :load FILE     run the script in FILE in the current environment
+  :reset         forget all bindings and macros
+  :time CODE     evaluate CODE and print how long it took
+  :help          show this help
+  :quit          leave the REPL
+`
+
+// session holds the state that lives across REPL inputs.
+type session struct {
+	in         io.Reader // stdin of the programs, shared with the REPL
+	out        io.Writer
+	engineName string
+	checked    bool // checked arithmetic, see EngineOptions
+	engine     Engine
+	macroEnv   *object.Environment
+}
+
+func newSession(in io.Reader, out io.Writer, engineName string, checked bool) (*session, error) {
+	s := &session{in: in, out: out, engineName: engineName, checked: checked}
+	if err := s.reset(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *session) reset() error {
+	streams := evaluator.IO{Stdout: s.out, Stderr: s.out, Stdin: s.in}
+
+	engine, err := NewEngine(s.engineName, EngineOptions{Streams: streams, CheckedArithmetic: s.checked})
+	if err != nil {
+		return err
+	}
+
+	s.engine = engine
+	s.macroEnv = object.NewEnvironmentWithBuiltins(evaluator.NewBuiltins(streams))
+	s.macroEnv.SetCheckedArithmetic(s.checked)
+	return nil
+}
+
+// eval runs input in the session environment and prints the result.
+func (s *session) eval(filename, input string) {
+	evaluated, errors := Run(filename, input, s.engine, s.macroEnv)
+	if len(errors) != 0 {
+		PrintParserErrors(s.out, errors)
+		return
+	}
+
+	if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+		if errObj, ok := evaluated.(*object.Error); ok {
+			io.WriteString(s.out, errObj.StackTrace())
+		}
+	}
+}
+
+// runCommand executes a meta-command line such as ":env". It returns false
+// when the REPL should stop.
+func (s *session) runCommand(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":ast":
+		s.printAST(arg)
+	case ":tokens":
+		s.printTokens(arg)
+	case ":env":
+		s.printEnv()
+	case ":load":
+		s.load(arg)
+	case ":reset":
+		if err := s.reset(); err != nil {
+			fmt.Fprintln(s.out, err)
+			break
+		}
+		io.WriteString(s.out, "environment reset\n")
+	case ":time":
+		start := time.Now()
+		s.eval("", arg)
+		fmt.Fprintf(s.out, "took %s\n", time.Since(start))
+	case ":help":
+		io.WriteString(s.out, COMMANDS_HELP)
+	case ":quit", ":q":
+		return false
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, type :help for a list\n", name)
+	}
+
+	return true
+}
+
+func (s *session) printAST(input string) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		PrintParserErrors(s.out, p.Errors())
+		return
+	}
+
+	for _, stmt := range program.Statements {
+		fmt.Fprintf(s.out, "%T %s\n", stmt, stmt.String())
+	}
+}
+
+func (s *session) printTokens(input string) {
+	l := lexer.New(input)
+
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-8s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+func (s *session) printEnv() {
+	printBindings(s.out, "env", s.engine)
+	printBindings(s.out, "macros", s.macroEnv)
+}
+
+// bindings is implemented by both engines and macro environments.
+type bindings interface {
+	Names() []string
+	Get(name string) (object.Object, bool)
+}
+
+func printBindings(out io.Writer, title string, env bindings) {
+	names := env.Names()
+	fmt.Fprintf(out, "%s (%d):\n", title, len(names))
+
+	for _, name := range names {
+		if val, ok := env.Get(name); ok {
+			fmt.Fprintf(out, "\t%s = %s\n", name, val.Inspect())
+		}
+	}
+}
+
+func (s *session) load(filename string) {
+	if filename == "" {
+		io.WriteString(s.out, "usage: :load FILE\n")
+		return
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(s.out, "could not load %s: %s\n", filename, err)
+		return
+	}
+
+	s.eval(filename, string(src))
+}
@@ -0,0 +1,126 @@
+package repl
+
+import (
+	"fmt"
+	"junk/ast"
+	"junk/compiler"
+	"junk/evaluator"
+	"junk/object"
+	"junk/vm"
+)
+
+// Names of the available execution backends.
+const (
+	ENGINE_EVAL = "eval"
+	ENGINE_VM   = "vm"
+)
+
+// Engine executes programs whose macros have already been expanded. An
+// engine keeps its global bindings between calls to Execute.
+type Engine interface {
+	Execute(program ast.Node) object.Object
+	Names() []string
+	Get(name string) (object.Object, bool)
+}
+
+// EngineOptions configures an engine created by NewEngine.
+type EngineOptions struct {
+	Streams evaluator.IO // streams of builtins such as puts and input
+
+	// CheckedArithmetic makes int64 overflow an error instead of promoting
+	// the result to a big integer.
+	CheckedArithmetic bool
+}
+
+// NewEngine returns the engine registered under name, configured by opts.
+func NewEngine(name string, opts EngineOptions) (Engine, error) {
+	switch name {
+	case ENGINE_EVAL:
+		env := object.NewEnvironmentWithBuiltins(evaluator.NewBuiltins(opts.Streams))
+		env.SetCheckedArithmetic(opts.CheckedArithmetic)
+		return &evalEngine{env: env}, nil
+	case ENGINE_VM:
+		return &vmEngine{
+			symbolTable: compiler.NewSymbolTableWithBuiltins(),
+			constants:   []object.Object{},
+			globals:     make([]object.Object, vm.GlobalsSize),
+			builtins:    evaluator.NewBuiltins(opts.Streams),
+			checked:     opts.CheckedArithmetic,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown engine %q, want %q or %q", name, ENGINE_EVAL, ENGINE_VM)
+	}
+}
+
+// evalEngine runs programs with the tree-walking evaluator.
+type evalEngine struct {
+	env *object.Environment
+}
+
+func (e *evalEngine) Execute(program ast.Node) object.Object {
+	return evaluator.Eval(program, e.env)
+}
+
+func (e *evalEngine) Names() []string { return e.env.Names() }
+
+func (e *evalEngine) Get(name string) (object.Object, bool) { return e.env.Get(name) }
+
+// vmEngine compiles programs to bytecode and runs them on the virtual machine.
+type vmEngine struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	builtins    map[string]*object.Builtin
+	checked     bool
+}
+
+func (e *vmEngine) Execute(node ast.Node) object.Object {
+	// compile against a copy, so that a program that does not compile
+	// leaves no names behind whose globals were never set
+	symbolTable := e.symbolTable.Copy()
+	comp := compiler.NewWithState(symbolTable, e.constants)
+	if err := comp.Compile(node); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	bytecode := comp.Bytecode()
+	e.symbolTable = symbolTable
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, e.globals)
+	machine.SetBuiltins(e.builtins)
+	machine.SetCheckedArithmetic(e.checked)
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	if machine.Returned() {
+		return machine.LastPoppedStackElem()
+	}
+
+	// Only expression statements leave a value behind, mirror what the
+	// evaluator returns for the other statements.
+	program, ok := node.(*ast.Program)
+	if !ok || len(program.Statements) == 0 {
+		return nil
+	}
+
+	switch program.Statements[len(program.Statements)-1].(type) {
+	case *ast.ExpressionStatement:
+		return machine.LastPoppedStackElem()
+	case *ast.WhileStatement, *ast.ForStatement, *ast.ForInStatement:
+		return evaluator.NULL
+	default:
+		return nil
+	}
+}
+
+func (e *vmEngine) Names() []string { return e.symbolTable.Names() }
+
+func (e *vmEngine) Get(name string) (object.Object, bool) {
+	symbol, ok := e.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope || e.globals[symbol.Index] == nil {
+		return nil, false
+	}
+	return e.globals[symbol.Index], true
+}
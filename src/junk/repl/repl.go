@@ -4,13 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"junk/evaluator"
 	"junk/lexer"
-	"junk/object"
 	"junk/parser"
+	"junk/token"
+	"strings"
 )
 
 const PROMPT = ">> "
+const CONTINUE_PROMPT = ".. "
 const RACCOON_JUNK = `
   _             _    
   (_)_   _ _ __ | | __
@@ -21,39 +22,90 @@ const RACCOON_JUNK = `
 `
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	macroEnv := object.NewEnvironment()
+	StartWithEngine(in, out, ENGINE_EVAL, false)
+}
+
+// StartWithEngine runs the REPL on the engine registered under engineName.
+// When checked is set, int64 overflow is an error, see EngineOptions.
+func StartWithEngine(in io.Reader, out io.Writer, engineName string, checked bool) {
+	reader := bufio.NewReader(in) // shared with the input builtin
+	s, err := newSession(reader, out, engineName, checked)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+
+	var pending strings.Builder // lines of an incomplete input
 
 	for {
-		fmt.Print(PROMPT)         // print prompt
-		scanned := scanner.Scan() // scan input
-		if !scanned {
+		if pending.Len() == 0 {
+			fmt.Fprint(out, PROMPT) // print prompt
+		} else {
+			fmt.Fprint(out, CONTINUE_PROMPT)
+		}
+
+		line, ok := readLine(reader) // get input
+		if !ok {
 			return
 		}
 
-		line := scanner.Text() // get input
-		l := lexer.New(line)   // create lexer
-		p := parser.New(l)
+		if pending.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !s.runCommand(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		pending.WriteString(line)
+		pending.WriteString("\n")
+
+		// an empty line forces evaluation of whatever has been typed so far
+		if strings.TrimSpace(line) != "" && isIncomplete(pending.String()) {
 			continue
 		}
 
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+		input := pending.String()
+		pending.Reset()
 
-		evaluated := evaluator.Eval(expanded, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		s.eval("", input)
+	}
+}
+
+// readLine returns the next line of r without its line ending. It reports
+// false at the end of the input.
+func readLine(r *bufio.Reader) (string, bool) {
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true
+}
+
+// isIncomplete reports whether src stops in the middle of a statement, e.g.
+// with an unclosed '{', '(' or '[' or a dangling operator. Such input is
+// continued on the next line instead of being reported as an error.
+func isIncomplete(src string) bool {
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+
+	for _, d := range p.Diagnostics() {
+		if d.Actual.Type == token.EOF {
+			return true
+		}
+		// an unclosed raw string or block comment runs up to the end of input
+		if d.Actual.Type == token.ILLEGAL && d.End.Offset == len(src) &&
+			(strings.HasPrefix(d.Actual.Literal, "`") || strings.HasPrefix(d.Actual.Literal, "/*")) {
+			return true
 		}
 	}
+
+	return false
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// PrintParserErrors writes parser errors in the same format the REPL uses.
+func PrintParserErrors(out io.Writer, errors []string) {
 	io.WriteString(out, RACCOON_JUNK)
 	io.WriteString(out, "Woops! We ran into some junk here!\n")
 	io.WriteString(out, " parser errors:\n")
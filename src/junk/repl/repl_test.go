@@ -0,0 +1,184 @@
+package repl
+
+import (
+	"bytes"
+	"junk/evaluator"
+	"junk/object"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let f = func(x) {", true},
+		{"let f = func(x) {\n x * 2\n};", false},
+		{"add(1,", true},
+		{"[1, 2", true},
+		{"1 +", true},
+		{"let x", true},
+		{"let = 5;", false},
+		{"if (x) { 1 } else", true},
+		{"let s = `first line", true},
+		{"let s = `first line\nsecond`;", false},
+		{"1 /* still", true},
+		{"let s = \"no end", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := "let double = func(x) {\n  x * 2\n};\ndouble(21)\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + CONTINUE_PROMPT + CONTINUE_PROMPT + PROMPT + "42\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartInputAndOutput(t *testing.T) {
+	input := "puts(\"hello\");\nlet name = input(\"name? \");\nbob\nname\n"
+
+	for _, engineName := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+		StartWithEngine(strings.NewReader(input), &out, engineName, false)
+
+		expected := PROMPT + "hello\nnull\n" + PROMPT + "name? " + PROMPT + "bob\n" + PROMPT
+		if out.String() != expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", engineName, expected, out.String())
+		}
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1;\n:env\n", []string{"env (1):", "a = 1", "macros (0):"}},
+		{"let m = macro(x) { x };\n:env\n", []string{"env (0):", "macros (1):", "m = macro(x)"}},
+		{":ast 1 + 2 * 3\n", []string{"(1 + (2 * 3))"}},
+		{":tokens let x\n", []string{"LET", `"let"`, "IDENT", "EOF"}},
+		{"let a = 1;\n:reset\na\n", []string{"environment reset", "identifier not found: a"}},
+		{":time 1 + 1\n", []string{"2", "took "}},
+		{":load does-not-exist.junk\n", []string{"could not load does-not-exist.junk"}},
+		{":nope\n", []string{"unknown command :nope"}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		for _, want := range tt.expected {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("input %q: output does not contain %q. got=%q", tt.input, want, out.String())
+			}
+		}
+	}
+}
+
+func TestEnginesAgree(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 5; a * 2", "10"},
+		{"let a = 5;", ""},
+		{"let x = 0; while (x < 3) { let x = x + 1; }", "null"},
+		{"return 1; 2", "1"},
+		{"let add = func(x, y) { x + y }; add(1, 2)", "3"},
+		{"1 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(1 > 2, 3, 4)", "3"},
+	}
+
+	for _, engineName := range []string{ENGINE_EVAL, ENGINE_VM} {
+		for _, tt := range tests {
+			engine, err := NewEngine(engineName, EngineOptions{})
+			if err != nil {
+				t.Fatalf("NewEngine(%q) failed: %s", engineName, err)
+			}
+
+			evaluated, errors := Run("", tt.input, engine, object.NewEnvironment())
+			if len(errors) != 0 {
+				t.Fatalf("%s: parser errors for %q: %q", engineName, tt.input, errors)
+			}
+
+			got := ""
+			if evaluated != nil {
+				got = evaluated.Inspect()
+			}
+
+			if got != tt.expected {
+				t.Errorf("%s: wrong result for %q. expected=%q, got=%q", engineName, tt.input, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestEngineStreams(t *testing.T) {
+	for _, engineName := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var stdout, stderr bytes.Buffer
+		streams := evaluator.IO{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("a\nb")}
+
+		engine, err := NewEngine(engineName, EngineOptions{Streams: streams})
+		if err != nil {
+			t.Fatalf("NewEngine(%q) failed: %s", engineName, err)
+		}
+
+		input := `puts(1, "two"); eputs("oops"); puts(input(), input(), input());`
+		if _, errors := Run("", input, engine, object.NewEnvironment()); len(errors) != 0 {
+			t.Fatalf("%s: parser errors: %q", engineName, errors)
+		}
+
+		if stdout.String() != "1\ntwo\na\nb\nnull\n" {
+			t.Errorf("%s: wrong stdout. got=%q", engineName, stdout.String())
+		}
+		if stderr.String() != "oops\n" {
+			t.Errorf("%s: wrong stderr. got=%q", engineName, stderr.String())
+		}
+	}
+}
+
+func TestVMEngineStateAfterErrors(t *testing.T) {
+	tests := []struct {
+		before   string
+		input    string
+		expected string
+	}{
+		// nothing runs when the program does not compile
+		{"let a = 1; let b = c;", "a + 1", "ERROR: identifier not found: a"},
+		{"let a = 1; let b = c;", "b", "ERROR: identifier not found: b"},
+		// a runtime error leaves the globals after it unset
+		{"let a = 1 / 0; let b = 2;", "b", "ERROR: global variable 1 used before it was set"},
+	}
+
+	for _, tt := range tests {
+		engine, err := NewEngine(ENGINE_VM, EngineOptions{})
+		if err != nil {
+			t.Fatalf("NewEngine failed: %s", err)
+		}
+		Run("", tt.before, engine, object.NewEnvironment())
+
+		evaluated, _ := Run("", tt.input, engine, object.NewEnvironment())
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q after %q. expected=%q, got=%v", tt.input, tt.before, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := NewEngine("jit", EngineOptions{}); err == nil {
+		t.Errorf("expected an error for an unknown engine")
+	}
+}
@@ -0,0 +1,26 @@
+package repl
+
+import (
+	"junk/evaluator"
+	"junk/lexer"
+	"junk/object"
+	"junk/parser"
+)
+
+// Run takes src through the lexer, the parser, macro expansion and the
+// engine. When the parser reports errors nothing is executed and the errors
+// are returned instead.
+func Run(filename, src string, engine Engine, macroEnv *object.Environment) (object.Object, []string) {
+	l := lexer.NewWithFilename(filename, src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()
+	}
+
+	evaluator.DefineMacros(program, macroEnv)
+	expanded := evaluator.ExpandMacros(program, macroEnv)
+
+	return engine.Execute(expanded), nil
+}
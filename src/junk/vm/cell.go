@@ -0,0 +1,21 @@
+package vm
+
+import "junk/object"
+
+// cell holds a local variable that has been captured by a closure. The
+// stack slot of the local and the Free slot of the closure point to the
+// same cell, so an assignment on either side is seen by the other.
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return "CELL" }
+func (c *cell) Inspect() string         { return c.value.Inspect() }
+
+// deref returns the value held by o when it is a cell, and o otherwise.
+func deref(o object.Object) object.Object {
+	if c, ok := o.(*cell); ok {
+		return c.value
+	}
+	return o
+}
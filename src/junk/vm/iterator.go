@@ -0,0 +1,13 @@
+package vm
+
+import "junk/object"
+
+// iterator is the state of a for-in loop. It holds the values bound by
+// every iteration, computed up front by OpIter, and the next one to use.
+type iterator struct {
+	steps [][]object.Object
+	next  int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
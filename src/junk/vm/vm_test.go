@@ -0,0 +1,649 @@
+package vm
+
+import (
+	"junk/compiler"
+	"junk/lexer"
+	"junk/object"
+	"junk/parser"
+	"testing"
+)
+
+// The test cases below are the ones in evaluator_test.go, so that both
+// backends are held to the same behaviour.
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+// vmError is the expected message of a runtime error.
+type vmError string
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"true || false", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"let fail = func() { 1 + true }; false && fail()", false},
+		{"let fail = func() { 1 + true }; true || fail()", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", Null},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { let a = 1; }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+			if (10 > 1) {
+				if (10 > 1) {
+					return 10;
+				}
+				return 1;
+			}
+		`, 10},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []vmTestCase{
+		{"5 + true;", vmError("type mismatch: INTEGER + BOOLEAN")},
+		{"5 + true; 5;", vmError("type mismatch: INTEGER + BOOLEAN")},
+		{"-true", vmError("unknown operator: -BOOLEAN")},
+		{"true + false;", vmError("unknown operator: BOOLEAN + BOOLEAN")},
+		{"true + false + true + false;", vmError("unknown operator: BOOLEAN + BOOLEAN")},
+		{"5; true + false; 5", vmError("unknown operator: BOOLEAN + BOOLEAN")},
+		{"if (10 > 1) { true + false; }", vmError("unknown operator: BOOLEAN + BOOLEAN")},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`, vmError("unknown operator: BOOLEAN + BOOLEAN")},
+		{`"Hello" - "World!"`, vmError("unknown operator: STRING - STRING")},
+		{`{"name": "Monkey"}[func(x) { x }];`, vmError("unusable as hash key: FUNCTION")},
+		{`1(2)`, vmError("not a function: INTEGER")},
+		{`func(x) { x }()`, vmError("wrong number of arguments: want=1, got=0")},
+		{"1 / 0", vmError("division by zero")},
+		{"let x = 0; 5 % x", vmError("modulo by zero")},
+		{"1 / 0.0", vmError("division by zero")},
+		{"1.5 % 0", vmError("modulo by zero")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []vmTestCase{
+		{"let identity = func(x) { x; }; identity(5);", 5},
+		{"let identity = func(x) { return x; }; identity(5);", 5},
+		{"let double = func(x) { x * 2; }; double(5);", 10},
+		{"let add = func(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = func(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"func(x) { x; }(5)", 5},
+		{"let noReturn = func() { }; noReturn();", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = func(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = func(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = func(a, b = a * 2) { b }; f(4)", 8},
+		{"let f = func(a = 1, b = a + 1) { [a, b] }; f()", []int{1, 2}},
+		{"let f = func(a, ...rest) { rest }; f(1, 2, 3)", []int{2, 3}},
+		{"let f = func(a, ...rest) { rest }; f(1)", []int{}},
+		{"let f = func(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7)", []int{1, 5, 2}},
+		{"let f = func(a, b = 2) { func() { a + b } }; f(1)()", 3},
+		{"let f = func(a, ...rest) { let x = 1; rest }; f(1, 2)", []int{2}},
+		{"let f = func(x) { x }; f(1, 2)", vmError("wrong number of arguments: want=1, got=2")},
+		{"let f = func(x, y = 1) { x }; f()", vmError("wrong number of arguments: want=1 to 2, got=0")},
+		{"let f = func(x, ...rest) { x }; f()", vmError("wrong number of arguments: want at least 1, got=0")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let newAdder = func(x) {
+			func(y) { x + y };
+		};
+		let addTwo = newAdder(2);
+		addTwo(2);
+		`, 4},
+		{`
+		let wrapper = func() {
+			let countDown = func(x) {
+				if (x == 0) { return 0; } else { countDown(x - 1); }
+			};
+			countDown(1);
+		};
+		wrapper();
+		`, 0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 1; a = 2; a", 2},
+		{"let a = 1; a += 4; a", 5},
+		{"let a = 10; a -= 4", 6},
+		{"let a = 3; a *= 4; a", 12},
+		{"let a = 12; a /= 4; a", 3},
+		{"let a = 12; a %= 5; a", 2},
+		{"let a = 1; let b = a = 7; a + b", 14},
+		{"let counter = 0; let inc = func() { counter += 1 }; inc(); inc(); counter", 2},
+		{"let a = 1; let f = func() { let a = 10; a = 20; a }; f() + a", 21},
+		{`
+		let newCounter = func() {
+			let n = 0;
+			func() { n += 1; n };
+		};
+		let c = newCounter();
+		c(); c(); c();
+		`, 3},
+		{`
+		let sum = func(n) {
+			let i = 0;
+			let total = 0;
+			let add = func() { total += i };
+			while (i < n) { add(); i += 1 };
+			total;
+		};
+		sum(4);
+		`, 6},
+		{`
+		let outer = func() {
+			let n = 1;
+			let middle = func() { func() { n *= 10 } };
+			middle()();
+			n;
+		};
+		outer();
+		`, 10},
+		{`
+		let f = func() {
+			let x = 1;
+			let g = func() { x };
+			x = 2;
+			g();
+		};
+		f() + f();
+		`, 4},
+		{"x = 1", vmError("cannot assign to undefined variable: x")},
+		{"len = 1", vmError("cannot assign to builtin: len")},
+		{"let f = func() { f = 1 }; f()", vmError("assigning to f inside its own body is not supported by the compiler")},
+		{"let f = func() { let g = func() { f = 1 }; g() }; f()", vmError("assigning to f inside its own body is not supported by the compiler")},
+		{"let f = func() { let g = func() { func() { f += 1 } }; g()() }; f()", vmError("assigning to f inside its own body is not supported by the compiler")},
+		{"let a = 1; a += true", vmError("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = [1, 2, 3]; a[0] = 10; a[0]", 10},
+		{"let a = [1, 2, 3]; a[2] += 5; a[2]", 8},
+		{"let a = [1, 2, 3]; let b = a; b[1] = 20; a[1]", 20},
+		{"let a = [[1], [2]]; a[1][0] *= 7; a[1][0]", 14},
+		{"let a = [0, 0]; let i = 0; while (i < 2) { a[i] = i + 1; i += 1 }; a[0] + a[1]", 3},
+		{"let h = {}; h[\"k\"] = 3; h[\"k\"]", 3},
+		{"let h = {\"k\": 3}; h[\"k\"] -= 1; h[\"k\"]", 2},
+		{"let h = {1: 1}; h[true] = 5; h[1] + h[true]", 6},
+		{"let h = {}; let f = func() { h[\"n\"] = 9 }; f(); h[\"n\"]", 9},
+		{"let a = [1]; a[0] = 4", 4},
+		{"let a = [1, 2]; a[2] = 3", vmError("index out of range: 2 (length 2)")},
+		{"let a = [1, 2]; a[-1] = 3", vmError("index out of range: -1 (length 2)")},
+		{"let a = [1, 2]; a[\"x\"] = 3", vmError("array index must be INTEGER, got STRING")},
+		{"let h = {}; h[[1]] = 3", vmError("unusable as hash key: ARRAY")},
+		{"let h = {}; h[func(x) { x }] = 3", vmError("unusable as hash key: FUNCTION")},
+		{"let s = \"abc\"; s[0] = \"x\"", vmError("index assignment not supported: STRING")},
+		{"let h = {}; h[\"k\"] += 1", vmError("type mismatch: NULL + INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []vmTestCase{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"99999999999999999999 - 99999999999999999998", 1},
+		{"let min = -9223372036854775807 - 1; -min - 1", 9223372036854775807},
+		{"99999999999999999999 / 0", vmError("division by zero")},
+	}
+
+	runVmTests(t, tests)
+
+	result, err := runVm("let fact = func(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)")
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if result.Inspect() != "15511210043330985984000000" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"ab" * 3`, "ababab"},
+		{`"a" == "a"`, true},
+		{`"a" < "b"`, true},
+		{"[1, [2]] == [1, [2]]", true},
+		{`{"a": [1]} != {"a": [2]}`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len(1)`, vmError("argument to `len` not supported, got INTEGER")},
+		{`len("one", "two")`, vmError("wrong number of arguments. got=2, want=1")},
+		{`first([1, 2, 3])`, 1},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+		{`values({"b": 1, "a": 2})`, []int{1, 2}},
+		{`has({"a": 1}, "a")`, true},
+		{`len(keys(merge({"a": 1}, {"b": 2})))`, 2},
+		{`fromEntries([["a", 1]])["a"]`, 1},
+		{`delete({"a": 1}, "a")["a"]`, Null},
+		{`keys(1)`, vmError("argument to `keys` must be HASH, got INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2 * 2, 3 + 3]", []int{1, 4, 6}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", Null},
+		{"[1, 2, 3][-1]", Null},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, Null},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, Null},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{1: 5}[1.0]`, 5},
+		{`{1.5: 5}[1]`, Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two:   1 + 1,
+		"thr" + "ee": 6 / 2,
+		4:     4,
+		true:  5,
+		false: 6,
+	}`
+
+	result, err := runVm(input)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	hash, ok := result.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", result, result)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		True.HashKey():                             5,
+		False.HashKey():                            6,
+	}
+
+	if hash.Len() != len(expected) {
+		t.Fatalf("hash has wrong number of pairs. got=%d", hash.Len())
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := hash.Get(expectedKey)
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+			continue
+		}
+
+		testExpectedObject(t, "hash literal", expectedValue, pair.Value)
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 0; while (x < 10) { let x = x + 1; } x", 10},
+		{"let x = 0; while (false) { let x = x * 123; } x", 0},
+		{`
+		let count = func(n) {
+			let i = 0;
+			while (i < n) { let i = i + 1; }
+			i
+		};
+		count(5);
+		`, 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestWhileLoopReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = func() { while (true) { return 7; } }; f()", 7},
+		{"let f = func() { let i = 0; while (true) { i += 1; if (i == 3) { return i * 10; } } }; f()", 30},
+		{"let f = func() { while (true) { while (true) { return 1; } } return 2; }; f()", 1},
+		{"let f = func() { while (false) { return 1; } 2 }; f()", 2},
+		{"while (true) { return 5; } 6", 5},
+		{"while (true) { 1 + true; }", vmError("type mismatch: INTEGER + BOOLEAN")},
+		{"let i = 0; while (i + true) { i += 1 }", vmError("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let sum = 0; for (let i = 0; i < 5; i += 1) { sum += i }; sum", 10},
+		{"let i = 0; for (; i < 3;) { i += 1 } i", 3},
+		{"let s = 0; for (let i = 0; ; i += 1) { if (i == 5) { break } if (i % 2 == 0) { continue } s += i }; s", 4},
+		{"let fs = []; for (let i = 0; i < 3; i += 1) { fs = push(fs, func() { i }) }; fs[0]() + fs[1]() * 10 + fs[2]() * 100", 210},
+		{"let f = func() { for (let i = 0; i < 10; i += 1) { if (i * i > 20) { return i } } -1 }; f()", 5},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x }; s", 6},
+		{"let s = 0; for (i, x in [10, 20]) { s += i * x }; s", 20},
+		{"let s = 0; for (k, v in {\"a\": 1, \"b\": 2}) { s += v }; s", 3},
+		{"let n = 0; for (k in {\"a\": 1, \"bc\": 2}) { n += len(k) }; n", 3},
+		{"let s = \"\"; for (k in {\"z\": 1, \"y\": 2, \"x\": 3}) { s += k }; s", "zyx"},
+		{"let n = 0; for (c in \"héllo\") { n += 1 }; n", 5},
+		{"let s = 0; for (i, c in \"héllo\") { s = s * 10 + i }; s", 1234},
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, func() { x }) }; fs[0]() + fs[1]()", 3},
+		{"let fs = []; for (x in [1, 2]) { let y = x * 10; fs = push(fs, func() { y }) }; fs[0]() + fs[1]()", 30},
+		// a break skips the end of the iteration, but still detaches its bindings
+		{"let fs = []; let n = 0; while (n < 2) { for (x in [n*10, n*10+1]) { fs = push(fs, func() { x }); break; } n += 1; } fs[0]() + fs[1]() * 100", 1000},
+		{"let fs = []; let n = 0; while (n < 2) { for (let i = n; i < 9; i += 1) { let y = i * 10; fs = push(fs, func() { i + y }); break; } n += 1; } fs[0]() + fs[1]() * 100", 1100},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue } if (x == 4) { break } s += x }; s", 4},
+		{"let f = func(arr) { for (x in arr) { if (x > 1) { return x } } 0 }; f([1, 5, 9])", 5},
+		{"let x = 7; for (x in [1, 2]) { }; x", 7},
+		{`
+		let count = func() {
+			let pairs = 0;
+			for (let i = 0; i < 3; i += 1) {
+				for (let j = 0; j < 3; j += 1) {
+					if (j == i) { continue }
+					pairs += 1;
+				}
+			}
+			pairs;
+		};
+		count();
+		`, 6},
+		{"for (let i = 0; i < 3; i += 1) { }; i", vmError("identifier not found: i")},
+		{"for (x in 5) { }", vmError("cannot iterate over INTEGER")},
+		{"for (let i = 0; i < 3; i += true) { }", vmError("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break; } } i", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue; } sum += i; } sum", 25},
+		{`
+		let i = 0;
+		let pairs = 0;
+		while (i < 3) {
+			i += 1;
+			let j = 0;
+			while (true) {
+				j += 1;
+				if (j > i) { break; }
+				pairs += 1;
+			}
+		}
+		pairs;
+		`, 6},
+		{`
+		let firstOver = func(arr, limit) {
+			let i = 0;
+			let found = -1;
+			while (i < len(arr)) {
+				if (arr[i] > limit) { found = arr[i]; break; }
+				i += 1;
+			}
+			found;
+		};
+		firstOver([1, 5, 9, 12], 6);
+		`, 9},
+		{"break;", vmError("break outside loop")},
+		{"if (true) { continue; }", vmError("continue outside loop")},
+		{"while (true) { let f = func() { break; }; }", vmError("break outside loop")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctionTooDeep(t *testing.T) {
+	_, err := runVm("let f = func(x) { f(x + 1) }; f(0);")
+	if err == nil {
+		t.Fatalf("expected a stack overflow error")
+	}
+}
+
+func runVm(input string) (object.Object, error) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		return nil, err
+	}
+
+	return vm.LastPoppedStackElem(), nil
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		result, err := runVm(tt.input)
+
+		if expectedErr, ok := tt.expected.(vmError); ok {
+			if err == nil {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, expectedErr, result)
+			} else if err.Error() != string(expectedErr) {
+				t.Errorf("%q: wrong error. want=%q, got=%q", tt.input, expectedErr, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: vm error: %s", tt.input, err)
+			continue
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		testExpectedObject(t, input, int64(expected), actual)
+
+	case int64:
+		result, ok := actual.(*object.Integer)
+		if !ok || result.Value != expected {
+			t.Errorf("%q: wrong integer. want=%d, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok || result.Value != expected {
+			t.Errorf("%q: wrong boolean. want=%t, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case string:
+		result, ok := actual.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("%q: wrong string. want=%q, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("%q: object not Array. got=%T (%+v)", input, actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("%q: wrong num of elements. want=%d, got=%d",
+				input, len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			testExpectedObject(t, input, expectedElem, array.Elements[i])
+		}
+
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%q: object is not Null. got=%T (%+v)", input, actual, actual)
+		}
+	}
+}